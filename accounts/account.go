@@ -1,16 +1,18 @@
 package accounts
 
+import "github.com/HHpCpp/AVAF/amount"
+
 // Account представляет собой аккаунт пользователя
 type Account struct {
-	Address    string             `json:"address"`    // Уникальный идентификатор аккаунта
-	Balance    map[string]float64 `json:"balances"`   // Баланс аккаунта (валюта -> float64)
-	PrivateKey string             `json:"privateKey"` // Приватный ключ для восстановления
+	Address    string                   `json:"address"`    // Уникальный идентификатор аккаунта
+	Balance    map[string]amount.Amount `json:"balances"`   // Баланс аккаунта (валюта -> сумма в nano-AVAF)
+	PrivateKey string                   `json:"privateKey"` // Приватный ключ для восстановления
 }
 
 // NewAccount создает новый аккаунт
-func NewAccount(address string, balance float64, privateKey string) Account {
-	// Инициализируем баланс как map[string]float64
-	balances := make(map[string]float64)
+func NewAccount(address string, balance amount.Amount, privateKey string) Account {
+	// Инициализируем баланс как map[string]amount.Amount
+	balances := make(map[string]amount.Amount)
 	balances["AVAF"] = balance // Пример для валюты "AVAF"
 
 	return Account{
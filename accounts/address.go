@@ -4,10 +4,9 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 
-	"golang.org/x/crypto/sha3"
+	"github.com/HHpCpp/AVAF/crypto"
 )
 
 // GenerateKeyPair генерирует пару ключей (приватный и публичный) и адрес
@@ -19,15 +18,7 @@ func GenerateKeyPair() (*ecdsa.PrivateKey, string, error) {
 	}
 
 	// Генерация адреса из публичного ключа
-	pubKey := append(
-		privateKey.PublicKey.X.Bytes(),
-		privateKey.PublicKey.Y.Bytes()...,
-	)
-
-	// Хешируем публичный ключ с помощью Keccak-256
-	hash := sha3.NewLegacyKeccak256()
-	hash.Write(pubKey)
-	address := "AVAFu" + hex.EncodeToString(hash.Sum(nil)[12:])
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
 
 	return privateKey, address, nil
 }
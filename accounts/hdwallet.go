@@ -0,0 +1,239 @@
+package accounts
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+var (
+	ErrHDWalletExists = errors.New("hd wallet already exists")
+	ErrHDAccount      = errors.New("account is derived from an hd wallet; back up its mnemonic instead")
+)
+
+// HDWallet — иерархический кошелек. Seed BIP-39 хранится зашифрованным
+// паролем, а публичный расширенный ключ цепочки внешних адресов
+// m/44'/HDCoinType'/0'/0 — открыто, чтобы выводить адреса без пароля.
+type HDWallet struct {
+	ID        string            `json:"id"`
+	Crypto    crypto.CryptoJSON `json:"crypto"`
+	PublicKey string            `json:"publicKey"` // hex(X || Y) ключа цепочки
+	ChainCode string            `json:"chainCode"`
+}
+
+func hdWalletKey(id string) string {
+	return "hdwallet_" + id
+}
+
+// CreateHDWallet создает кошелек из новой мнемоники BIP-39 и возвращает его
+// идентификатор и мнемонику. Мнемоника больше нигде не хранится: ее нужно
+// записать, по ней RestoreHDWallet восстановит те же адреса.
+func (am *AccountManager) CreateHDWallet(password string) (string, string, error) {
+	mnemonic, err := crypto.NewMnemonic()
+	if err != nil {
+		return "", "", err
+	}
+	id, err := am.RestoreHDWallet(mnemonic, password)
+	if err != nil {
+		return "", "", err
+	}
+	return id, mnemonic, nil
+}
+
+// RestoreHDWallet сохраняет кошелек по мнемонике. Идентификатор выводится из
+// ключей, поэтому у восстановленного кошелька он тот же, что у исходного.
+func (am *AccountManager) RestoreHDWallet(mnemonic, password string) (string, error) {
+	seed, err := crypto.MnemonicToSeed(mnemonic, "")
+	if err != nil {
+		return "", err
+	}
+
+	master, err := crypto.NewMasterKey(seed)
+	if err != nil {
+		return "", err
+	}
+	chain, err := master.DerivePath(fmt.Sprintf("m/44'/%d'/0'/0", crypto.HDCoinType))
+	if err != nil {
+		return "", err
+	}
+
+	publicKey := crypto.MarshalPublicKey(chain.PublicKey)
+	sum := sha256.Sum256(append(publicKey, chain.ChainCode...))
+	id := hex.EncodeToString(sum[:8])
+
+	cryptoJSON, err := crypto.EncryptData(seed, password, am.KDF)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt seed: %w", err)
+	}
+
+	data, err := json.Marshal(HDWallet{
+		ID:        id,
+		Crypto:    *cryptoJSON,
+		PublicKey: hex.EncodeToString(publicKey),
+		ChainCode: hex.EncodeToString(chain.ChainCode),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal hd wallet: %w", err)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if _, err := am.db.Load(hdWalletKey(id)); err == nil {
+		return "", fmt.Errorf("%w: %s", ErrHDWalletExists, id)
+	}
+	if err := am.db.Save(hdWalletKey(id), data); err != nil {
+		return "", fmt.Errorf("failed to save hd wallet: %w", err)
+	}
+	return id, nil
+}
+
+// LoadHDWallet загружает запись иерархического кошелька
+func (am *AccountManager) LoadHDWallet(id string) (HDWallet, error) {
+	data, err := am.db.Load(hdWalletKey(id))
+	if err != nil {
+		return HDWallet{}, fmt.Errorf("failed to load hd wallet: %w", err)
+	}
+
+	var wallet HDWallet
+	if err := json.Unmarshal(data, &wallet); err != nil {
+		return HDWallet{}, fmt.Errorf("failed to unmarshal hd wallet: %w", err)
+	}
+	return wallet, nil
+}
+
+// DeriveAccount выводит аккаунт с номером index по пути HDAccountPath и
+// сохраняет его. Пароль не нужен: адрес вычисляется из публичного ключа
+// цепочки, а приватный ключ GetPrivateKey выводит из seed при подписи.
+// Повторный вызов для того же индекса возвращает существующий аккаунт.
+func (am *AccountManager) DeriveAccount(walletID string, index uint32) (string, error) {
+	if index >= crypto.HardenedOffset {
+		return "", fmt.Errorf("invalid account index %d", index)
+	}
+
+	hdWallet, err := am.LoadHDWallet(walletID)
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := decodePublicKey(hdWallet.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	chainCode, err := hex.DecodeString(hdWallet.ChainCode)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode chain code: %w", err)
+	}
+
+	chain := &crypto.ExtendedKey{PublicKey: publicKey, ChainCode: chainCode}
+	child, err := chain.Child(index)
+	if err != nil {
+		return "", err
+	}
+	address := crypto.PubkeyToAddress(*child.PublicKey)
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	wallet, err := am.LoadAccount(address)
+	switch {
+	case errors.Is(err, adb.ErrNotFound):
+		wallet = Wallet{
+			Address: address,
+			Balance: map[string]amount.Amount{"AVAF": amount.Zero},
+		}
+	case err != nil:
+		return "", err
+	case wallet.Crypto.Cipher != "" || wallet.HDWalletID != "":
+		// Ключ аккаунта уже доступен
+		return address, nil
+	}
+
+	wallet.HDWalletID = walletID
+	wallet.HDIndex = index
+	wallet.PublicKey = hex.EncodeToString(crypto.MarshalPublicKey(child.PublicKey))
+
+	if err := am.saveAccount(wallet); err != nil {
+		return "", fmt.Errorf("failed to save account: %w", err)
+	}
+
+	cacheMutex.Lock()
+	publicKeyCache[address] = child.PublicKey
+	cacheMutex.Unlock()
+
+	return address, nil
+}
+
+// hdPrivateKey выводит приватный ключ аккаунта из seed его кошелька
+func (am *AccountManager) hdPrivateKey(wallet Wallet, password string) (*ecdsa.PrivateKey, error) {
+	hdWallet, err := am.LoadHDWallet(wallet.HDWalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := crypto.DecryptData(hdWallet.Crypto, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt seed: %w", err)
+	}
+
+	master, err := crypto.NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.DerivePath(crypto.HDAccountPath(wallet.HDIndex))
+	if err != nil {
+		return nil, err
+	}
+
+	if address := crypto.PubkeyToAddress(key.PrivateKey.PublicKey); address != wallet.Address {
+		return nil, fmt.Errorf("hd wallet %s index %d derives %s, not %s", wallet.HDWalletID, wallet.HDIndex, address, wallet.Address)
+	}
+	return key.PrivateKey, nil
+}
+
+// changeHDPassword перешифровывает seed кошелька новым паролем.
+// Пароль общий для всех аккаунтов кошелька.
+func (am *AccountManager) changeHDPassword(walletID, oldPassword, newPassword string) error {
+	hdWallet, err := am.LoadHDWallet(walletID)
+	if err != nil {
+		return err
+	}
+
+	seed, err := crypto.DecryptData(hdWallet.Crypto, oldPassword)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt seed: %w", err)
+	}
+
+	cryptoJSON, err := crypto.EncryptData(seed, newPassword, am.KDF)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt seed: %w", err)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	current, err := am.LoadHDWallet(walletID)
+	if err != nil {
+		return err
+	}
+	if current.Crypto != hdWallet.Crypto {
+		return fmt.Errorf("%w: hd wallet %s", ErrKeyChanged, walletID)
+	}
+	current.Crypto = *cryptoJSON
+
+	data, err := json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hd wallet: %w", err)
+	}
+	if err := am.db.Save(hdWalletKey(walletID), data); err != nil {
+		return fmt.Errorf("failed to save hd wallet: %w", err)
+	}
+	return nil
+}
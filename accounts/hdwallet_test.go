@@ -0,0 +1,64 @@
+package accounts
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+// newTestManager создает менеджер аккаунтов в памяти с быстрым KDF
+func newTestManager() *AccountManager {
+	am := NewAccountManager(adb.NewMemoryDB())
+	am.KDF = crypto.KDFLight()
+	return am
+}
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestRestoreHDWalletDerivesSameAccounts(t *testing.T) {
+	var walletIDs, addresses []string
+	for _, am := range []*AccountManager{newTestManager(), newTestManager()} {
+		id, err := am.RestoreHDWallet(testMnemonic, "password")
+		if err != nil {
+			t.Fatalf("RestoreHDWallet: %v", err)
+		}
+		address, err := am.DeriveAccount(id, 1)
+		if err != nil {
+			t.Fatalf("DeriveAccount: %v", err)
+		}
+
+		// Ключ подписи выводится из seed и соответствует адресу
+		privateKey, err := am.GetPrivateKey(address, "password")
+		if err != nil {
+			t.Fatalf("GetPrivateKey: %v", err)
+		}
+		if got := crypto.PubkeyToAddress(privateKey.PublicKey); got != address {
+			t.Fatalf("private key belongs to %s, want %s", got, address)
+		}
+		if _, err := am.GetPrivateKey(address, "wrong"); err == nil {
+			t.Fatal("GetPrivateKey accepted a wrong password")
+		}
+
+		walletIDs = append(walletIDs, id)
+		addresses = append(addresses, address)
+	}
+
+	if walletIDs[0] != walletIDs[1] || addresses[0] != addresses[1] {
+		t.Fatalf("restored wallets differ: ids %v, addresses %v", walletIDs, addresses)
+	}
+}
+
+func TestRestoreHDWalletRejects(t *testing.T) {
+	am := newTestManager()
+	if _, err := am.RestoreHDWallet(testMnemonic, "password"); err != nil {
+		t.Fatalf("RestoreHDWallet: %v", err)
+	}
+	if _, err := am.RestoreHDWallet(testMnemonic, "password"); !errors.Is(err, ErrHDWalletExists) {
+		t.Fatalf("second RestoreHDWallet error = %v, want %v", err, ErrHDWalletExists)
+	}
+	if _, err := am.RestoreHDWallet("abandon abandon abandon", "password"); !errors.Is(err, crypto.ErrInvalidMnemonic) {
+		t.Fatalf("RestoreHDWallet of an invalid mnemonic error = %v, want %v", err, crypto.ErrInvalidMnemonic)
+	}
+}
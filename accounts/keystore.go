@@ -0,0 +1,158 @@
+package accounts
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+var (
+	ErrAccountExists = errors.New("account already has a key")
+	ErrLegacyKey     = errors.New("account key uses the legacy format; change its password to upgrade it")
+)
+
+// keystoreFileName возвращает имя файла ключа в принятом у кошельков виде
+// UTC--<время>--<адрес>
+func keystoreFileName(address string, t time.Time) string {
+	return fmt.Sprintf("UTC--%s--%s", t.UTC().Format("2006-01-02T15-04-05.000000000Z"), address)
+}
+
+// ExportKeystore записывает ключ аккаунта в отдельный файл Web3 Secret
+// Storage v3 в каталоге dir и возвращает путь к файлу. Ключ остается
+// зашифрованным паролем аккаунта, поэтому пароль не нужен.
+func (am *AccountManager) ExportKeystore(address, dir string) (string, error) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	wallet, err := am.LoadAccount(address)
+	if err != nil {
+		return "", err
+	}
+	if wallet.HDWalletID != "" {
+		return "", fmt.Errorf("%w: %s", ErrHDAccount, address)
+	}
+	if wallet.Crypto.Cipher == "" {
+		return "", fmt.Errorf("account %s has no private key", address)
+	}
+	if wallet.Version != crypto.KeystoreVersion {
+		return "", fmt.Errorf("%w: %s", ErrLegacyKey, address)
+	}
+
+	keystore := wallet.Keystore()
+	data, err := json.MarshalIndent(keystore, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal keystore: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create keystore directory: %w", err)
+	}
+	path := filepath.Join(dir, keystoreFileName(keystore.Address, time.Now()))
+
+	// O_EXCL: файл ключа никогда не перезаписывается
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create keystore file: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write keystore file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write keystore file: %w", err)
+	}
+	return path, nil
+}
+
+// ImportKeystore добавляет аккаунт из файла v3. Пароль нужен, чтобы
+// убедиться, что ключ расшифровывается и соответствует адресу файла;
+// сохраняется исходная зашифрованная запись.
+func (am *AccountManager) ImportKeystore(path, password string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read keystore file: %w", err)
+	}
+
+	var keystore crypto.Keystore
+	if err := json.Unmarshal(data, &keystore); err != nil {
+		return "", fmt.Errorf("failed to unmarshal keystore: %w", err)
+	}
+
+	privateKey, err := crypto.DecryptKey(&keystore, password)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt keystore: %w", err)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.importKey(privateKey, &keystore)
+}
+
+// ImportRawPrivateKey добавляет аккаунт по hex приватного ключа P-256,
+// шифруя ключ паролем с параметрами am.KDF
+func (am *AccountManager) ImportRawPrivateKey(privateKeyHex, password string) (string, error) {
+	keyBytes, err := hex.DecodeString(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode private key hex: %w", err)
+	}
+	privateKey, err := crypto.PrivateKeyFromBytes(keyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	keystore, err := crypto.EncryptKey(privateKey, password, am.KDF)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.importKey(privateKey, keystore)
+}
+
+// importKey сохраняет зашифрованный ключ под каноничным адресом. Аккаунт без
+// ключа (например, распределение генезиса) получает ключ с сохранением баланса
+// и nonce; аккаунт, у которого ключ уже есть, не перезаписывается.
+func (am *AccountManager) importKey(privateKey *ecdsa.PrivateKey, keystore *crypto.Keystore) (string, error) {
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	wallet, err := am.LoadAccount(address)
+	switch {
+	case errors.Is(err, adb.ErrNotFound):
+		wallet = Wallet{
+			Address: address,
+			Balance: map[string]amount.Amount{"AVAF": amount.Zero},
+		}
+	case err != nil:
+		return "", err
+	case wallet.Crypto.Cipher != "" || wallet.HDWalletID != "":
+		return "", fmt.Errorf("%w: %s", ErrAccountExists, address)
+	}
+
+	wallet.Crypto = keystore.Crypto
+	wallet.ID = keystore.ID
+	wallet.Version = keystore.Version
+	wallet.PublicKey = hex.EncodeToString(crypto.MarshalPublicKey(&privateKey.PublicKey))
+
+	if err := am.saveAccount(wallet); err != nil {
+		return "", fmt.Errorf("failed to save account: %w", err)
+	}
+
+	cacheMutex.Lock()
+	publicKeyCache[address] = &privateKey.PublicKey
+	cacheMutex.Unlock()
+
+	return address, nil
+}
@@ -0,0 +1,135 @@
+package accounts
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+func TestExportImportKeystore(t *testing.T) {
+	source := newTestManager()
+	address, privateKey, err := source.CreateAccount("password")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "keystore")
+	path, err := source.ExportKeystore(address, dir)
+	if err != nil {
+		t.Fatalf("ExportKeystore: %v", err)
+	}
+	if name := filepath.Base(path); !strings.HasPrefix(name, "UTC--") ||
+		!strings.HasSuffix(name, "--"+strings.TrimPrefix(address, crypto.AddressPrefix)) {
+		t.Fatalf("keystore file name = %s", name)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Fatalf("keystore file mode = %o, want 600", mode)
+	}
+
+	target := newTestManager()
+	if _, err := target.ImportKeystore(path, "wrong"); err == nil {
+		t.Fatal("ImportKeystore accepted a wrong password")
+	}
+	imported, err := target.ImportKeystore(path, "password")
+	if err != nil {
+		t.Fatalf("ImportKeystore: %v", err)
+	}
+	if imported != address {
+		t.Fatalf("imported address = %s, want %s", imported, address)
+	}
+	decrypted, err := target.GetPrivateKey(address, "password")
+	if err != nil {
+		t.Fatalf("GetPrivateKey: %v", err)
+	}
+	if decrypted.D.Cmp(privateKey.D) != 0 {
+		t.Fatal("imported key differs from the exported one")
+	}
+
+	if _, err := target.ImportKeystore(path, "password"); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("second ImportKeystore error = %v, want %v", err, ErrAccountExists)
+	}
+}
+
+func TestExportKeystoreRejects(t *testing.T) {
+	am := newTestManager()
+	dir := t.TempDir()
+
+	// Ключ старого формата нужно сначала перешифровать
+	if err := am.db.Save("account_"+legacyAccountAddress, []byte(legacyAccountJSON)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := am.ExportKeystore(legacyAccountAddress, dir); !errors.Is(err, ErrLegacyKey) {
+		t.Fatalf("ExportKeystore of a legacy key error = %v, want %v", err, ErrLegacyKey)
+	}
+
+	// Ключ аккаунта иерархического кошелька восстанавливается мнемоникой
+	id, err := am.RestoreHDWallet(testMnemonic, "password")
+	if err != nil {
+		t.Fatalf("RestoreHDWallet: %v", err)
+	}
+	address, err := am.DeriveAccount(id, 0)
+	if err != nil {
+		t.Fatalf("DeriveAccount: %v", err)
+	}
+	if _, err := am.ExportKeystore(address, dir); !errors.Is(err, ErrHDAccount) {
+		t.Fatalf("ExportKeystore of an HD account error = %v, want %v", err, ErrHDAccount)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("rejected exports wrote %d files", len(entries))
+	}
+}
+
+func TestImportRawPrivateKey(t *testing.T) {
+	const keyHex = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+	keyBytes, _ := hex.DecodeString(keyHex)
+	privateKey, err := crypto.PrivateKeyFromBytes(keyBytes)
+	if err != nil {
+		t.Fatalf("PrivateKeyFromBytes: %v", err)
+	}
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	// Аккаунт уже получил средства, но ключа на узле у него нет
+	am := newTestManager()
+	setState(t, am, Wallet{Address: address, Balance: map[string]amount.Amount{"AVAF": amount.MustParse("5")}, Nonce: 2})
+
+	if _, err := am.ImportRawPrivateKey("zz", "password"); err == nil {
+		t.Fatal("ImportRawPrivateKey accepted invalid hex")
+	}
+	imported, err := am.ImportRawPrivateKey("0x"+keyHex, "password")
+	if err != nil {
+		t.Fatalf("ImportRawPrivateKey: %v", err)
+	}
+	if imported != address {
+		t.Fatalf("imported address = %s, want %s", imported, address)
+	}
+
+	decrypted, err := am.GetPrivateKey(address, "password")
+	if err != nil {
+		t.Fatalf("GetPrivateKey: %v", err)
+	}
+	if decrypted.D.Cmp(privateKey.D) != 0 {
+		t.Fatal("imported key differs from the raw key")
+	}
+	wallet, err := am.LoadAccount(address)
+	if err != nil {
+		t.Fatalf("LoadAccount: %v", err)
+	}
+	if wallet.Balance["AVAF"] != amount.MustParse("5") || wallet.Nonce != 2 {
+		t.Fatalf("state after import = %s, nonce %d; want 5, nonce 2", wallet.Balance["AVAF"], wallet.Nonce)
+	}
+
+	if _, err := am.ImportRawPrivateKey(keyHex, "other"); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("second ImportRawPrivateKey error = %v, want %v", err, ErrAccountExists)
+	}
+}
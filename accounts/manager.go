@@ -5,37 +5,70 @@ import (
 	"crypto/elliptic"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
 	"sync"
 
 	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
 	"github.com/HHpCpp/AVAF/crypto"
 )
 
+// ErrKeyChanged возвращается ChangePassword, если ключ аккаунта был
+// перешифрован другим вызовом, пока шла смена пароля
+var ErrKeyChanged = errors.New("account key changed concurrently")
+
 var (
 	publicKeyCache = make(map[string]*ecdsa.PublicKey)
 	cacheMutex     sync.RWMutex
 )
 
+// AccountManager владеет записями аккаунтов. mu сериализует все изменения
+// записей по схеме «прочитать, изменить, записать»: фиксацию блоков,
+// стейкинг, смену пароля и импорт ключей. Поэтому у цепочки должен быть
+// один AccountManager, общий для всех ее компонентов.
 type AccountManager struct {
-	mu sync.RWMutex
-	db *adb.LevelDB
+	mu  sync.RWMutex
+	db  adb.KVStore
+	KDF crypto.KDFOptions // Параметры KDF для новых ключей
 }
 
+// Wallet — запись аккаунта. Поля address, crypto, id и version образуют файл
+// ключа Web3 Secret Storage v3; у записей старого формата version равен 0,
+// а в crypto зашифрован hex приватного ключа. У аккаунта иерархического
+// кошелька crypto пуст: ключ выводится из seed кошелька HDWalletID.
 type Wallet struct {
-	Address   string             `json:"address"`
-	Crypto    crypto.CryptoJSON  `json:"crypto"`
-	Balance   map[string]float64 `json:"balances"` // Изменено на float64
-	PublicKey string             `json:"publicKey"`
+	Address    string                   `json:"address"`
+	Crypto     crypto.CryptoJSON        `json:"crypto"`
+	ID         string                   `json:"id,omitempty"`
+	Version    int                      `json:"version,omitempty"`
+	Balance    map[string]amount.Amount `json:"balances"` // Суммы в nano-AVAF
+	PublicKey  string                   `json:"publicKey"`
+	Nonce      uint64                   `json:"nonce"` // Nonce следующей исходящей транзакции
+	HDWalletID string                   `json:"hdWallet,omitempty"`
+	HDIndex    uint32                   `json:"hdIndex,omitempty"` // Номер в пути HDAccountPath
+}
+
+// Keystore возвращает файл ключа v3 аккаунта
+func (w Wallet) Keystore() crypto.Keystore {
+	return crypto.Keystore{
+		Address: strings.TrimPrefix(w.Address, crypto.AddressPrefix),
+		Crypto:  w.Crypto,
+		ID:      w.ID,
+		Version: w.Version,
+	}
 }
 
-func NewAccountManager(db *adb.LevelDB) *AccountManager {
-	return &AccountManager{db: db}
+func NewAccountManager(db adb.KVStore) *AccountManager {
+	return &AccountManager{db: db, KDF: crypto.KDFStandard()}
 }
 
-func (am *AccountManager) SaveAccount(wallet Wallet) error {
+// saveAccount сохраняет запись аккаунта; вызывается под am.mu. Балансы
+// меняются только генезисом и фиксацией блоков, поэтому снаружи пакета
+// записи аккаунтов пишутся лишь в пакет изменений (PutAccount).
+func (am *AccountManager) saveAccount(wallet Wallet) error {
 	data, err := json.Marshal(wallet)
 	if err != nil {
 		return fmt.Errorf("failed to marshal wallet: %w", err)
@@ -46,8 +79,53 @@ func (am *AccountManager) SaveAccount(wallet Wallet) error {
 	return am.db.Save(key, data)
 }
 
+// PutAccount добавляет запись аккаунта в пакет изменений
+func (am *AccountManager) PutAccount(batch *adb.Batch, wallet Wallet) error {
+	data, err := json.Marshal(wallet)
+	if err != nil {
+		return fmt.Errorf("failed to marshal wallet %s: %w", wallet.Address, err)
+	}
+	batch.Save("account_"+wallet.Address, data)
+	return nil
+}
+
+// PutAccountState добавляет в пакет баланс и nonce аккаунта, записывая их
+// в сохраненную запись. Остальные поля, в том числе зашифрованный ключ,
+// берутся из базы, а не из wallet, поэтому состояние, прочитанное до смены
+// пароля, не вернет старый ключ. Для адреса без записи, например получателя
+// перевода с другого узла, создается запись только с адресом, балансом и nonce.
+// Вызывается внутри Update.
+func (am *AccountManager) PutAccountState(batch *adb.Batch, wallet Wallet) error {
+	current, err := am.LoadAccount(wallet.Address)
+	if errors.Is(err, adb.ErrNotFound) {
+		current, err = Wallet{Address: wallet.Address}, nil
+	}
+	if err != nil {
+		return err
+	}
+	current.Balance = wallet.Balance
+	current.Nonce = wallet.Nonce
+	return am.PutAccount(batch, current)
+}
+
+// Update выполняет fn под блокировкой аккаунтов и атомарно записывает пакет.
+// Аккаунты, прочитанные внутри fn через LoadAccount, не изменятся до записи
+// пакета. Внутри fn нельзя вызывать методы, которые сами берут блокировку.
+func (am *AccountManager) Update(fn func(batch *adb.Batch) error) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return adb.Update(am.db, fn)
+}
+
+// LoadAccount загружает аккаунт; для адреса, перенесенного MigrateAddresses,
+// возвращает аккаунт по его каноничному адресу
 func (am *AccountManager) LoadAccount(address string) (Wallet, error) {
 	data, err := am.db.Load("account_" + address)
+	if errors.Is(err, adb.ErrNotFound) {
+		if alias, aliasErr := am.db.Load(addressAliasKey(address)); aliasErr == nil {
+			data, err = am.db.Load("account_" + string(alias))
+		}
+	}
 	if err != nil {
 		return Wallet{}, fmt.Errorf("failed to load account: %w", err)
 	}
@@ -69,6 +147,24 @@ func (am *AccountManager) GetPrivateKey(address, password string) (*ecdsa.Privat
 		return nil, err
 	}
 
+	if wallet.HDWalletID != "" {
+		return am.hdPrivateKey(wallet, password)
+	}
+	return decryptWallet(wallet, password)
+}
+
+// decryptWallet расшифровывает приватный ключ записи аккаунта любого формата
+func decryptWallet(wallet Wallet, password string) (*ecdsa.PrivateKey, error) {
+	if wallet.Version == crypto.KeystoreVersion {
+		keystore := wallet.Keystore()
+		privateKey, err := crypto.DecryptKey(&keystore, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+		return privateKey, nil
+	}
+
+	// Запись старого формата: зашифрован hex приватного ключа
 	privateKeyBytes, err := crypto.DecryptData(wallet.Crypto, password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
@@ -88,7 +184,9 @@ func (am *AccountManager) GetPrivateKey(address, password string) (*ecdsa.Privat
 	return privateKey, nil
 }
 
-func (am *AccountManager) CreateAccount(password string, balance float64) (string, *ecdsa.PrivateKey, error) {
+// CreateAccount создает аккаунт с нулевым балансом; средства появляются
+// только из распределения генезиса или переводом от другого аккаунта
+func (am *AccountManager) CreateAccount(password string) (string, *ecdsa.PrivateKey, error) {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
@@ -97,26 +195,23 @@ func (am *AccountManager) CreateAccount(password string, balance float64) (strin
 		return "", nil, fmt.Errorf("failed to generate key pair: %w", err)
 	}
 
-	privateKeyHex := hex.EncodeToString(privateKey.D.Bytes())
-
-	cryptoJSON, err := crypto.EncryptData([]byte(privateKeyHex), password)
+	keystore, err := crypto.EncryptKey(privateKey, password, am.KDF)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to encrypt private key: %w", err)
 	}
 
-	publicKeyHex := hex.EncodeToString(append(
-		privateKey.PublicKey.X.Bytes(),
-		privateKey.PublicKey.Y.Bytes()...,
-	))
+	publicKeyHex := hex.EncodeToString(crypto.MarshalPublicKey(&privateKey.PublicKey))
 
 	wallet := Wallet{
 		Address:   address,
-		Crypto:    *cryptoJSON,
-		Balance:   map[string]float64{"AVAF": balance},
+		Crypto:    keystore.Crypto,
+		ID:        keystore.ID,
+		Version:   keystore.Version,
+		Balance:   map[string]amount.Amount{"AVAF": amount.Zero},
 		PublicKey: publicKeyHex,
 	}
 
-	if err := am.SaveAccount(wallet); err != nil {
+	if err := am.saveAccount(wallet); err != nil {
 		return "", nil, fmt.Errorf("failed to save account: %w", err)
 	}
 
@@ -126,25 +221,72 @@ func (am *AccountManager) CreateAccount(password string, balance float64) (strin
 
 	return address, privateKey, nil
 }
+
+// ChangePassword перешифровывает ключ аккаунта новым паролем со свежими
+// солью и IV. Запись старого формата при этом переводится в формат v3.
+// Ключ заменяется одной записью, баланс и nonce аккаунта не меняются.
+// Для аккаунта иерархического кошелька меняется пароль seed, то есть
+// пароль всех аккаунтов этого кошелька. Если за время смены ключ уже
+// перешифровал другой вызов, возвращается ErrKeyChanged.
+func (am *AccountManager) ChangePassword(address, oldPassword, newPassword string) error {
+	wallet, err := am.LoadAccount(address)
+	if err != nil {
+		return err
+	}
+	if wallet.HDWalletID != "" {
+		return am.changeHDPassword(wallet.HDWalletID, oldPassword, newPassword)
+	}
+
+	// KDF работает долго, поэтому ключ перешифровывается без блокировки
+	privateKey, err := decryptWallet(wallet, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	keystore, err := crypto.EncryptKey(privateKey, newPassword, am.KDF)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	// Перечитываем запись под блокировкой: баланс мог измениться блоком,
+	// а ключ — другой сменой пароля, которую нельзя затирать
+	current, err := am.LoadAccount(address)
+	if err != nil {
+		return err
+	}
+	if current.Crypto != wallet.Crypto {
+		return fmt.Errorf("%w: %s", ErrKeyChanged, address)
+	}
+	current.Crypto = keystore.Crypto
+	current.Version = keystore.Version
+	if current.ID == "" {
+		current.ID = keystore.ID
+	}
+
+	if err := am.saveAccount(current); err != nil {
+		return fmt.Errorf("failed to save account: %w", err)
+	}
+	return nil
+}
+
 func (am *AccountManager) GetAllAccounts() ([]string, error) {
 	var Addresses []string
-	// Создаем итератор для LevelDB
-	iter := am.db.NewIterator()
+	// Итерируем только по ключам аккаунтов
+	iter := am.db.NewPrefixIterator("account_")
 	defer iter.Release()
 
-	// Проходим по всем ключам
+	// Проходим по всем аккаунтам
 	for iter.Next() {
-		key := string(iter.Key())
 		value := iter.Value()
 
-		// Проверяем, что ключ начинается с "account_"
-		if strings.HasPrefix(key, "account_") {
-			var account Account
-			if err := json.Unmarshal(value, &account); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal account: %w", err)
-			}
-			Addresses = append(Addresses, account.Address)
+		var account Account
+		if err := json.Unmarshal(value, &account); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account: %w", err)
 		}
+		Addresses = append(Addresses, account.Address)
 	}
 
 	if err := iter.Error(); err != nil {
@@ -153,7 +295,7 @@ func (am *AccountManager) GetAllAccounts() ([]string, error) {
 
 	return Addresses, nil
 }
-func (am *AccountManager) GetBalance(address string) (map[string]float64, error) {
+func (am *AccountManager) GetBalance(address string) (map[string]amount.Amount, error) {
 	am.mu.RLock()
 	defer am.mu.RUnlock()
 
@@ -165,21 +307,17 @@ func (am *AccountManager) GetBalance(address string) (map[string]float64, error)
 	return wallet.Balance, nil // Возвращаем баланс напрямую
 }
 
-func (am *AccountManager) UpdateBalance(address string, balances map[string]float64) error {
-	am.mu.Lock()
-	defer am.mu.Unlock()
+// GetNonce возвращает nonce, который должна иметь следующая транзакция аккаунта
+func (am *AccountManager) GetNonce(address string) (uint64, error) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
 
 	wallet, err := am.LoadAccount(address)
 	if err != nil {
-		return err
-	}
-
-	// Обновляем баланс для каждой валюты
-	for currency, balance := range balances {
-		wallet.Balance[currency] = balance // Просто обновляем значение
+		return 0, err
 	}
 
-	return am.SaveAccount(wallet)
+	return wallet.Nonce, nil
 }
 
 func (am *AccountManager) GetPublicKey(address string) (*ecdsa.PublicKey, error) {
@@ -195,19 +333,84 @@ func (am *AccountManager) GetPublicKey(address string) (*ecdsa.PublicKey, error)
 		return nil, err
 	}
 
-	pubKeyBytes, err := hex.DecodeString(wallet.PublicKey)
+	pubKey, err := decodePublicKey(wallet.PublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode public key: %w", err)
+		return nil, err
 	}
 
-	pubKey := new(ecdsa.PublicKey)
-	pubKey.Curve = elliptic.P256()
-	pubKey.X = new(big.Int).SetBytes(pubKeyBytes[:32])
-	pubKey.Y = new(big.Int).SetBytes(pubKeyBytes[32:])
-
 	cacheMutex.Lock()
 	publicKeyCache[address] = pubKey
 	cacheMutex.Unlock()
 
 	return pubKey, nil
 }
+
+// decodePublicKey разбирает публичный ключ аккаунта из hex
+func decodePublicKey(publicKeyHex string) (*ecdsa.PublicKey, error) {
+	pubKeyBytes, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	return crypto.UnmarshalPublicKey(pubKeyBytes)
+}
+
+// addressAliasKey возвращает ключ записи старый адрес -> каноничный адрес
+func addressAliasKey(address string) string {
+	return "addralias_" + address
+}
+
+// AddressMigration описывает перенос аккаунта со старого адреса на каноничный
+type AddressMigration struct {
+	OldAddress string `json:"oldAddress"`
+	NewAddress string `json:"newAddress"`
+}
+
+// MigrateAddresses находит аккаунты, адрес которых не совпадает с
+// crypto.PubkeyToAddress от их публичного ключа (адреса, полученные до
+// выравнивания координат ключа), и добавляет в пакет их перенос: запись
+// аккаунта сохраняется под каноничным адресом, ключ перекодируется в
+// 64-байтовый формат, а старый адрес становится псевдонимом нового,
+// чтобы подписи старых транзакций по-прежнему проверялись.
+// Вызывается внутри Update.
+func (am *AccountManager) MigrateAddresses(batch *adb.Batch) ([]AddressMigration, error) {
+	iter := am.db.NewPrefixIterator("account_")
+	defer iter.Release()
+
+	var migrations []AddressMigration
+	for iter.Next() {
+		var wallet Wallet
+		if err := json.Unmarshal(iter.Value(), &wallet); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account: %w", err)
+		}
+		if wallet.PublicKey == "" {
+			continue
+		}
+
+		pubKey, err := decodePublicKey(wallet.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", wallet.Address, err)
+		}
+		canonical := crypto.PubkeyToAddress(*pubKey)
+		if canonical == wallet.Address {
+			continue
+		}
+		if _, err := am.db.Load("account_" + canonical); err == nil {
+			return nil, fmt.Errorf("account %s: canonical address %s is already taken", wallet.Address, canonical)
+		}
+
+		oldAddress := wallet.Address
+		wallet.Address = canonical
+		wallet.PublicKey = hex.EncodeToString(crypto.MarshalPublicKey(pubKey))
+		if err := am.PutAccount(batch, wallet); err != nil {
+			return nil, err
+		}
+		batch.Delete("account_" + oldAddress)
+		batch.Save(addressAliasKey(oldAddress), []byte(canonical))
+
+		migrations = append(migrations, AddressMigration{OldAddress: oldAddress, NewAddress: canonical})
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return migrations, nil
+}
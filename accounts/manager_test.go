@@ -0,0 +1,153 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+// legacyAccountJSON — запись аккаунта aes-128-cbc, созданная до перехода
+// на формат v3, с паролем "pass1"
+const legacyAccountJSON = `{"address":"AVAFu3f093cd146875230446bf546f4777196e5b2617e","crypto":{"cipher":"aes-128-cbc","ciphercode":"f454e4565ff509f79ba14c48c80cf3af385f4342bf725e008983fcc2d2d2aa93ddc7da1b454fb83255b7f51d47012e4283ea24efe413e664baf7b5bc000f9ea7","cipherparams":{"iv":"ab54e5aa6d92da3e911c4c2710d7e07b"},"kdf":"scrypt","kdfparams":{"dklen":32,"n":262144,"p":1,"r":8,"salt":"55ba6a41db898309b4c74f0957b1a73f9ba14b5d359dd9e282d75d53b955836b"},"mac":"1843c75e629deac9314cebb810a3fecf1bc25fdba749bc1e1d3d2b73a985af92"},"balances":{"AVAF":100},"publicKey":"0c78b45485d8329ac286ce712e14824e1c6360cbd3bc7d528907cbb82311e61e327f9f5666c3390d864988b28d14d623d7f5acfa5df1ab1cbbf325d599aaa8c2"}`
+
+const legacyAccountAddress = "AVAFu3f093cd146875230446bf546f4777196e5b2617e"
+
+// setState записывает баланс и nonce аккаунта так же, как фиксация блока
+func setState(t *testing.T, am *AccountManager, wallet Wallet) {
+	t.Helper()
+	err := am.Update(func(batch *adb.Batch) error {
+		return am.PutAccountState(batch, wallet)
+	})
+	if err != nil {
+		t.Fatalf("PutAccountState: %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	am := newTestManager()
+	address, privateKey, err := am.CreateAccount("old")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+	setState(t, am, Wallet{Address: address, Balance: map[string]amount.Amount{"AVAF": amount.MustParse("7")}, Nonce: 3})
+
+	if err := am.ChangePassword(address, "wrong", "new"); err == nil {
+		t.Fatal("ChangePassword accepted a wrong old password")
+	}
+	if err := am.ChangePassword(address, "old", "new"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+
+	if _, err := am.GetPrivateKey(address, "old"); err == nil {
+		t.Fatal("old password still decrypts the key")
+	}
+	decrypted, err := am.GetPrivateKey(address, "new")
+	if err != nil {
+		t.Fatalf("GetPrivateKey: %v", err)
+	}
+	if decrypted.D.Cmp(privateKey.D) != 0 {
+		t.Fatal("key changed along with the password")
+	}
+
+	wallet, err := am.LoadAccount(address)
+	if err != nil {
+		t.Fatalf("LoadAccount: %v", err)
+	}
+	if wallet.Balance["AVAF"] != amount.MustParse("7") || wallet.Nonce != 3 {
+		t.Fatalf("state after ChangePassword = %s, nonce %d; want 7, nonce 3", wallet.Balance["AVAF"], wallet.Nonce)
+	}
+}
+
+func TestChangePasswordKeepsKeyOnStateUpdate(t *testing.T) {
+	am := newTestManager()
+	address, _, err := am.CreateAccount("old")
+	if err != nil {
+		t.Fatalf("CreateAccount: %v", err)
+	}
+
+	// Блок прочитал аккаунт до смены пароля и записывает состояние после нее
+	stale, err := am.LoadAccount(address)
+	if err != nil {
+		t.Fatalf("LoadAccount: %v", err)
+	}
+	if err := am.ChangePassword(address, "old", "new"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	stale.Balance = map[string]amount.Amount{"AVAF": amount.MustParse("1")}
+	stale.Nonce = 1
+	setState(t, am, stale)
+
+	if _, err := am.GetPrivateKey(address, "new"); err != nil {
+		t.Fatalf("state update restored the old key: %v", err)
+	}
+	if _, err := am.GetPrivateKey(address, "old"); err == nil {
+		t.Fatal("state update restored the old key")
+	}
+	if nonce, _ := am.GetNonce(address); nonce != 1 {
+		t.Fatalf("nonce = %d, want 1", nonce)
+	}
+}
+
+func TestChangePasswordUpgradesLegacyRecord(t *testing.T) {
+	am := newTestManager()
+	if err := am.db.Save("account_"+legacyAccountAddress, []byte(legacyAccountJSON)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if err := am.ChangePassword(legacyAccountAddress, "pass1", "new"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	wallet, err := am.LoadAccount(legacyAccountAddress)
+	if err != nil {
+		t.Fatalf("LoadAccount: %v", err)
+	}
+	if wallet.Crypto.Cipher != crypto.CipherAES128CTR || wallet.Version != crypto.KeystoreVersion {
+		t.Fatalf("record after ChangePassword: cipher %q, version %d; want v3", wallet.Crypto.Cipher, wallet.Version)
+	}
+
+	privateKey, err := am.GetPrivateKey(legacyAccountAddress, "new")
+	if err != nil {
+		t.Fatalf("GetPrivateKey: %v", err)
+	}
+	publicKey, err := am.GetPublicKey(legacyAccountAddress)
+	if err != nil {
+		t.Fatalf("GetPublicKey: %v", err)
+	}
+	if !privateKey.PublicKey.Equal(publicKey) {
+		t.Fatal("re-encrypted key does not match the account public key")
+	}
+}
+
+func TestChangePasswordOfHDAccount(t *testing.T) {
+	am := newTestManager()
+	id, err := am.RestoreHDWallet(testMnemonic, "old")
+	if err != nil {
+		t.Fatalf("RestoreHDWallet: %v", err)
+	}
+	first, err := am.DeriveAccount(id, 0)
+	if err != nil {
+		t.Fatalf("DeriveAccount: %v", err)
+	}
+	second, err := am.DeriveAccount(id, 1)
+	if err != nil {
+		t.Fatalf("DeriveAccount: %v", err)
+	}
+
+	// Пароль общий для seed, поэтому меняется у всех аккаунтов кошелька
+	if err := am.ChangePassword(first, "old", "new"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	for _, address := range []string{first, second} {
+		if _, err := am.GetPrivateKey(address, "new"); err != nil {
+			t.Fatalf("GetPrivateKey(%s): %v", address, err)
+		}
+		if _, err := am.GetPrivateKey(address, "old"); err == nil {
+			t.Fatalf("old password still decrypts %s", address)
+		}
+	}
+	if err := am.ChangePassword(second, "old", "other"); err == nil {
+		t.Fatal("ChangePassword accepted a replaced password")
+	}
+}
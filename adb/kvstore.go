@@ -0,0 +1,71 @@
+package adb
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+)
+
+// KVStore описывает хранилище ключ-значение, поверх которого работают
+// аккаунты, стейкинг и блокчейн
+type KVStore interface {
+	Save(key string, value []byte) error
+	Load(key string) ([]byte, error)
+	Delete(key string) error
+	NewIterator() iterator.Iterator
+	NewPrefixIterator(prefix string) iterator.Iterator
+	NewBatch() *Batch
+	Write(batch *Batch) error
+	NewSnapshot() (Snapshot, error)
+	Close() error
+}
+
+// Snapshot — согласованный срез хранилища на момент создания
+type Snapshot interface {
+	Load(key string) ([]byte, error)
+	NewIterator() iterator.Iterator
+	NewPrefixIterator(prefix string) iterator.Iterator
+	Release()
+}
+
+// Batch накапливает изменения, которые KVStore.Write применяет атомарно
+type Batch struct {
+	batch leveldb.Batch
+}
+
+// Save добавляет в пакет запись значения по ключу
+func (b *Batch) Save(key string, value []byte) {
+	b.batch.Put([]byte(key), value)
+}
+
+// Delete добавляет в пакет удаление ключа
+func (b *Batch) Delete(key string) {
+	b.batch.Delete([]byte(key))
+}
+
+// Len возвращает количество операций в пакете
+func (b *Batch) Len() int {
+	return b.batch.Len()
+}
+
+// Reset очищает пакет для повторного использования
+func (b *Batch) Reset() {
+	b.batch.Reset()
+}
+
+// Update собирает изменения в пакет через fn и атомарно записывает их.
+// Если fn возвращает ошибку, в хранилище ничего не попадает.
+func Update(db KVStore, fn func(batch *Batch) error) error {
+	batch := db.NewBatch()
+	if err := fn(batch); err != nil {
+		return err
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return db.Write(batch)
+}
+
+var (
+	_ KVStore = (*LevelDB)(nil)
+	_ KVStore = (*MemoryDB)(nil)
+)
@@ -0,0 +1,216 @@
+package adb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+)
+
+// testStores возвращает все реализации KVStore: поведение у них должно совпадать
+func testStores(t *testing.T) map[string]KVStore {
+	t.Helper()
+
+	level, err := NewLevelDB(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("NewLevelDB: %v", err)
+	}
+	t.Cleanup(func() { level.Close() })
+
+	return map[string]KVStore{
+		"memory":  NewMemoryDB(),
+		"leveldb": level,
+	}
+}
+
+// keys возвращает ключи, которые перечисляет итератор, в порядке обхода
+func keys(t *testing.T, iter iterator.Iterator) []string {
+	t.Helper()
+	defer iter.Release()
+
+	var result []string
+	for iter.Next() {
+		result = append(result, string(iter.Key()))
+	}
+	if err := iter.Error(); err != nil {
+		t.Fatalf("iterator error: %v", err)
+	}
+	return result
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestKVStoreSaveLoadDelete(t *testing.T) {
+	for name, db := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := db.Load("missing"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Load(missing) error = %v, want %v", err, ErrNotFound)
+			}
+
+			if err := db.Save("key", []byte("value")); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			value, err := db.Load("key")
+			if err != nil || string(value) != "value" {
+				t.Fatalf("Load = %q, %v; want %q", value, err, "value")
+			}
+
+			// Изменение возвращенного буфера не меняет хранилище
+			value[0] = 'X'
+			if value, _ := db.Load("key"); string(value) != "value" {
+				t.Fatalf("stored value changed through returned buffer: %q", value)
+			}
+
+			if err := db.Delete("key"); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if err := db.Delete("key"); err != nil {
+				t.Fatalf("Delete of a missing key: %v", err)
+			}
+			if _, err := db.Load("key"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Load after Delete error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestKVStorePrefixIterator(t *testing.T) {
+	for name, db := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"block_2", "account_a", "block_1", "blocks", "tx_1"} {
+				if err := db.Save(key, []byte(key)); err != nil {
+					t.Fatalf("Save: %v", err)
+				}
+			}
+
+			if got, want := keys(t, db.NewPrefixIterator("block_")), []string{"block_1", "block_2"}; !equalKeys(got, want) {
+				t.Fatalf("prefix keys = %v, want %v", got, want)
+			}
+			if got := keys(t, db.NewIterator()); len(got) != 5 {
+				t.Fatalf("all keys = %v, want 5 keys", got)
+			}
+		})
+	}
+}
+
+func TestKVStoreUpdateIsAtomic(t *testing.T) {
+	for name, db := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := db.Save("old", []byte("1")); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			// Ошибка в fn: ни одна операция пакета не применяется
+			failure := errors.New("failure")
+			err := Update(db, func(batch *Batch) error {
+				batch.Save("new", []byte("2"))
+				batch.Delete("old")
+				return failure
+			})
+			if !errors.Is(err, failure) {
+				t.Fatalf("Update error = %v, want %v", err, failure)
+			}
+			if _, err := db.Load("new"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("failed batch wrote a key: %v", err)
+			}
+			if _, err := db.Load("old"); err != nil {
+				t.Fatalf("failed batch deleted a key: %v", err)
+			}
+
+			err = Update(db, func(batch *Batch) error {
+				batch.Save("new", []byte("2"))
+				batch.Delete("old")
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if _, err := db.Load("new"); err != nil {
+				t.Fatalf("batch did not write a key: %v", err)
+			}
+			if _, err := db.Load("old"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("batch did not delete a key: %v", err)
+			}
+		})
+	}
+}
+
+func TestKVStoreSnapshot(t *testing.T) {
+	for name, db := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := db.Save("a_1", []byte("before")); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			snap, err := db.NewSnapshot()
+			if err != nil {
+				t.Fatalf("NewSnapshot: %v", err)
+			}
+			defer snap.Release()
+
+			// Снимок не видит изменений, сделанных после его создания
+			if err := db.Save("a_1", []byte("after")); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			if err := db.Save("a_2", []byte("new")); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			if value, err := snap.Load("a_1"); err != nil || string(value) != "before" {
+				t.Fatalf("snapshot Load = %q, %v; want %q", value, err, "before")
+			}
+			if _, err := snap.Load("a_2"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("snapshot Load(a_2) error = %v, want %v", err, ErrNotFound)
+			}
+			if got, want := keys(t, snap.NewPrefixIterator("a_")), []string{"a_1"}; !equalKeys(got, want) {
+				t.Fatalf("snapshot prefix keys = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOpenLevelDBRequiresExistingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing")
+	for name, open := range map[string]func(string) (*LevelDB, error){
+		"OpenLevelDB":         OpenLevelDB,
+		"OpenLevelDBReadOnly": OpenLevelDBReadOnly,
+	} {
+		if db, err := open(path); err == nil {
+			db.Close()
+			t.Fatalf("%s of a missing path succeeded", name)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s created %s: %v", name, path, err)
+		}
+	}
+
+	db, err := NewLevelDB(path)
+	if err != nil {
+		t.Fatalf("NewLevelDB: %v", err)
+	}
+	if err := db.Save("key", []byte("value")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	db.Close()
+
+	db, err = OpenLevelDB(path)
+	if err != nil {
+		t.Fatalf("OpenLevelDB: %v", err)
+	}
+	defer db.Close()
+	if value, err := db.Load("key"); err != nil || string(value) != "value" {
+		t.Fatalf("Load = %q, %v; want %q", value, err, "value")
+	}
+}
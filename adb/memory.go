@@ -0,0 +1,150 @@
+package adb
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/memdb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// MemoryDB — хранилище в памяти для тестов и встраивания узла без диска
+type MemoryDB struct {
+	mu sync.RWMutex // Делает запись пакета атомарной для читателей
+	db *memdb.DB
+}
+
+// NewMemoryDB создает пустое хранилище в памяти
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{db: memdb.New(comparer.DefaultComparer, 0)}
+}
+
+// Close освобождает данные хранилища
+func (m *MemoryDB) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.db.Reset()
+	return nil
+}
+
+// Save сохраняет данные по ключу
+func (m *MemoryDB) Save(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.db.Put([]byte(key), value)
+}
+
+// Load загружает данные по ключу
+func (m *MemoryDB) Load(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return loadCopy(m.db, key)
+}
+
+// Delete удаляет данные по ключу; отсутствие ключа не считается ошибкой, как в LevelDB
+func (m *MemoryDB) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.db.Delete([]byte(key)); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// NewIterator возвращает итератор по всем ключам. Итератор видит изменения,
+// сделанные после его создания; для согласованного чтения используйте NewSnapshot.
+func (m *MemoryDB) NewIterator() iterator.Iterator {
+	return m.db.NewIterator(nil)
+}
+
+// NewPrefixIterator возвращает итератор только по ключам с заданным префиксом
+func (m *MemoryDB) NewPrefixIterator(prefix string) iterator.Iterator {
+	return m.db.NewIterator(util.BytesPrefix([]byte(prefix)))
+}
+
+// NewBatch создает пустой пакет изменений
+func (m *MemoryDB) NewBatch() *Batch {
+	return new(Batch)
+}
+
+// Write атомарно применяет пакет изменений
+func (m *MemoryDB) Write(batch *Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	replay := &memoryReplay{db: m.db}
+	if err := batch.batch.Replay(replay); err != nil {
+		return err
+	}
+	return replay.err
+}
+
+// NewSnapshot копирует текущее содержимое хранилища
+func (m *MemoryDB) NewSnapshot() (Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snap := memdb.New(comparer.DefaultComparer, m.db.Size())
+	iter := m.db.NewIterator(nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := snap.Put(iter.Key(), iter.Value()); err != nil {
+			return nil, err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return &memorySnapshot{db: snap}, nil
+}
+
+// loadCopy возвращает копию значения, чтобы вызывающий код не держал ссылку на буфер memdb
+func loadCopy(db *memdb.DB, key string) ([]byte, error) {
+	value, err := db.Get([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// memoryReplay применяет операции пакета к memdb
+type memoryReplay struct {
+	db  *memdb.DB
+	err error
+}
+
+func (r *memoryReplay) Put(key, value []byte) {
+	if r.err == nil {
+		r.err = r.db.Put(key, value)
+	}
+}
+
+func (r *memoryReplay) Delete(key []byte) {
+	if r.err == nil {
+		if err := r.db.Delete(key); err != nil && !errors.Is(err, ErrNotFound) {
+			r.err = err
+		}
+	}
+}
+
+// memorySnapshot — неизменяемая копия MemoryDB
+type memorySnapshot struct {
+	db *memdb.DB
+}
+
+func (s *memorySnapshot) Load(key string) ([]byte, error) {
+	return loadCopy(s.db, key)
+}
+
+func (s *memorySnapshot) NewIterator() iterator.Iterator {
+	return s.db.NewIterator(nil)
+}
+
+func (s *memorySnapshot) NewPrefixIterator(prefix string) iterator.Iterator {
+	return s.db.NewIterator(util.BytesPrefix([]byte(prefix)))
+}
+
+func (s *memorySnapshot) Release() {
+	s.db.Reset()
+}
@@ -2,11 +2,17 @@ package adb
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
+// ErrNotFound возвращается Load, если ключ отсутствует в базе
+var ErrNotFound = leveldb.ErrNotFound
+
 type LevelDB struct {
 	db *leveldb.DB
 }
@@ -20,6 +26,32 @@ func NewLevelDB(path string) (*LevelDB, error) {
 	return &LevelDB{db: db}, nil
 }
 
+// OpenLevelDB открывает существующую базу для записи. В отличие от
+// NewLevelDB, для несуществующего пути возвращает ошибку, а не создает пустую базу.
+func OpenLevelDB(path string) (*LevelDB, error) {
+	// С ErrorIfMissing LevelDB все равно создает каталог с файлами
+	// блокировки и журнала, поэтому путь проверяется заранее
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	db, err := leveldb.OpenFile(path, &opt.Options{ErrorIfMissing: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	return &LevelDB{db: db}, nil
+}
+
+// OpenLevelDBReadOnly открывает существующую базу только для чтения.
+// В отличие от NewLevelDB, для несуществующего пути возвращает ошибку,
+// а не создает пустую базу.
+func OpenLevelDBReadOnly(path string) (*LevelDB, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
+	}
+	return &LevelDB{db: db}, nil
+}
+
 // Close закрывает подключение к LevelDB
 func (l *LevelDB) Close() error {
 	return l.db.Close()
@@ -39,6 +71,52 @@ func (l *LevelDB) Load(key string) ([]byte, error) {
 func (l *LevelDB) Delete(key string) error {
 	return l.db.Delete([]byte(key), nil)
 }
+
 func (l *LevelDB) NewIterator() iterator.Iterator {
 	return l.db.NewIterator(nil, nil)
 }
+
+// NewPrefixIterator возвращает итератор только по ключам с заданным префиксом
+func (l *LevelDB) NewPrefixIterator(prefix string) iterator.Iterator {
+	return l.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+}
+
+// NewBatch создает пустой пакет изменений
+func (l *LevelDB) NewBatch() *Batch {
+	return new(Batch)
+}
+
+// Write атомарно применяет пакет изменений
+func (l *LevelDB) Write(batch *Batch) error {
+	return l.db.Write(&batch.batch, nil)
+}
+
+// NewSnapshot создает снимок текущего состояния базы
+func (l *LevelDB) NewSnapshot() (Snapshot, error) {
+	snap, err := l.db.GetSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return &levelSnapshot{snap: snap}, nil
+}
+
+// levelSnapshot адаптирует снимок LevelDB к интерфейсу Snapshot
+type levelSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+func (s *levelSnapshot) Load(key string) ([]byte, error) {
+	return s.snap.Get([]byte(key), nil)
+}
+
+func (s *levelSnapshot) NewIterator() iterator.Iterator {
+	return s.snap.NewIterator(nil, nil)
+}
+
+func (s *levelSnapshot) NewPrefixIterator(prefix string) iterator.Iterator {
+	return s.snap.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+}
+
+func (s *levelSnapshot) Release() {
+	s.snap.Release()
+}
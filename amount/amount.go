@@ -0,0 +1,192 @@
+// Package amount реализует денежные суммы AVAF в целых базовых единицах (nano-AVAF)
+package amount
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Decimals — количество знаков после запятой у AVAF
+	Decimals = 9
+	// NanoPerAVAF — количество базовых единиц в одном AVAF
+	NanoPerAVAF = 1_000_000_000
+)
+
+var (
+	ErrOverflow  = errors.New("amount overflow")
+	ErrUnderflow = errors.New("amount underflow")
+	ErrInvalid   = errors.New("invalid amount")
+)
+
+// Amount — сумма в nano-AVAF. Вся арифметика целочисленная и проверяет переполнение.
+type Amount uint64
+
+// Zero — нулевая сумма
+const Zero Amount = 0
+
+// Max — максимальная представимая сумма
+const Max Amount = math.MaxUint64
+
+// FromNano создает сумму из количества базовых единиц
+func FromNano(nano uint64) Amount {
+	return Amount(nano)
+}
+
+// Nano возвращает сумму в базовых единицах
+func (a Amount) Nano() uint64 {
+	return uint64(a)
+}
+
+// IsZero сообщает, равна ли сумма нулю
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+// Add возвращает a + b или ErrOverflow
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum, carry := bits.Add64(uint64(a), uint64(b), 0)
+	if carry != 0 {
+		return 0, ErrOverflow
+	}
+	return Amount(sum), nil
+}
+
+// Sub возвращает a - b или ErrUnderflow, если b больше a
+func (a Amount) Sub(b Amount) (Amount, error) {
+	diff, borrow := bits.Sub64(uint64(a), uint64(b), 0)
+	if borrow != 0 {
+		return 0, ErrUnderflow
+	}
+	return Amount(diff), nil
+}
+
+// Mul возвращает a * n или ErrOverflow
+func (a Amount) Mul(n uint64) (Amount, error) {
+	hi, lo := bits.Mul64(uint64(a), n)
+	if hi != 0 {
+		return 0, ErrOverflow
+	}
+	return Amount(lo), nil
+}
+
+// Sum складывает несколько сумм с проверкой переполнения
+func Sum(amounts ...Amount) (Amount, error) {
+	var total Amount
+	for _, a := range amounts {
+		var err error
+		if total, err = total.Add(a); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// String форматирует сумму как десятичное число AVAF без лишних нулей, например "12.5"
+func (a Amount) String() string {
+	whole := uint64(a) / NanoPerAVAF
+	frac := uint64(a) % NanoPerAVAF
+	if frac == 0 {
+		return strconv.FormatUint(whole, 10)
+	}
+	fracStr := strings.TrimRight(fmt.Sprintf("%0*d", Decimals, frac), "0")
+	return strconv.FormatUint(whole, 10) + "." + fracStr
+}
+
+// Parse разбирает десятичную запись AVAF ("12", "0.0001") в точную сумму.
+// Больше Decimals знаков после запятой, знак и экспонента не допускаются.
+func Parse(s string) (Amount, error) {
+	wholeStr, fracStr, hasDot := strings.Cut(s, ".")
+	if wholeStr == "" && (!hasDot || fracStr == "") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	if hasDot && fracStr == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+	if len(fracStr) > Decimals {
+		// Допускаем только незначащие нули сверх точности
+		if strings.TrimRight(fracStr[Decimals:], "0") != "" {
+			return 0, fmt.Errorf("%w: %q has more than %d decimals", ErrInvalid, s, Decimals)
+		}
+		fracStr = fracStr[:Decimals]
+	}
+	if !isDigits(wholeStr) || !isDigits(fracStr) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	var whole uint64
+	if wholeStr != "" {
+		var err error
+		whole, err = strconv.ParseUint(wholeStr, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrOverflow, s)
+		}
+	}
+
+	var frac uint64
+	if fracStr != "" {
+		fracStr += strings.Repeat("0", Decimals-len(fracStr))
+		frac, _ = strconv.ParseUint(fracStr, 10, 64)
+	}
+
+	total, err := Amount(whole).Mul(NanoPerAVAF)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", err, s)
+	}
+	return total.Add(Amount(frac))
+}
+
+// MustParse разбирает сумму и паникует при ошибке; предназначена для констант
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON сохраняет сумму строкой, чтобы JSON-клиенты не теряли точность
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON принимает строку или число; числа поддерживаются для записей,
+// сохраненных до перехода на целочисленные суммы, и округляются до Decimals знаков
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		// Старые float64-балансы округляем до точности Amount
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalid, s)
+		}
+		s = strconv.FormatFloat(f, 'f', Decimals, 64)
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
@@ -0,0 +1,107 @@
+package amount
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		nano uint64
+		out  string
+	}{
+		{"0", 0, "0"},
+		{"12", 12 * NanoPerAVAF, "12"},
+		{"12.5", 12_500_000_000, "12.5"},
+		{"0.0001", 100_000, "0.0001"},
+		{".5", 500_000_000, "0.5"},
+		{"0.000000001", 1, "0.000000001"},
+		{"1.0000000000", NanoPerAVAF, "1"},
+		{"18446744073.709551615", 18446744073709551615, "18446744073.709551615"},
+	} {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.in, err)
+		}
+		if got.Nano() != tt.nano {
+			t.Fatalf("Parse(%q) = %d nano, want %d", tt.in, got.Nano(), tt.nano)
+		}
+		if got.String() != tt.out {
+			t.Fatalf("String(%q) = %q, want %q", tt.in, got.String(), tt.out)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want error
+	}{
+		{"", ErrInvalid},
+		{".", ErrInvalid},
+		{"1.", ErrInvalid},
+		{"-1", ErrInvalid},
+		{"+1", ErrInvalid},
+		{"1e3", ErrInvalid},
+		{"1.2.3", ErrInvalid},
+		{"0.0000000001", ErrInvalid},
+		{"18446744073.709551616", ErrOverflow},
+		{"18446744074", ErrOverflow},
+		{"99999999999999999999", ErrOverflow},
+	} {
+		if _, err := Parse(tt.in); !errors.Is(err, tt.want) {
+			t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestArithmeticOverflow(t *testing.T) {
+	if _, err := Max.Add(1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Max.Add(1) error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := Max.Mul(2); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Max.Mul(2) error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := Sum(Max, 1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Sum(Max, 1) error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := Zero.Sub(1); !errors.Is(err, ErrUnderflow) {
+		t.Fatalf("Zero.Sub(1) error = %v, want %v", err, ErrUnderflow)
+	}
+
+	sum, err := MustParse("0.1").Add(MustParse("0.2"))
+	if err != nil || sum != MustParse("0.3") {
+		t.Fatalf("0.1 + 0.2 = %s, %v; want 0.3", sum, err)
+	}
+	fee, err := MustParse("0.0001").Mul(1000)
+	if err != nil || fee != MustParse("0.1") {
+		t.Fatalf("0.0001 * 1000 = %s, %v; want 0.1", fee, err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(MustParse("12.5"))
+	if err != nil || string(data) != `"12.5"` {
+		t.Fatalf("Marshal = %s, %v; want %q", data, err, `"12.5"`)
+	}
+
+	// Строки и числа старого формата дают одну и ту же сумму
+	for _, in := range []string{`"0.0001"`, `0.0001`, ` 1e-4 `} {
+		var a Amount
+		if err := json.Unmarshal([]byte(in), &a); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", in, err)
+		}
+		if a != MustParse("0.0001") {
+			t.Fatalf("Unmarshal(%s) = %s, want 0.0001", in, a)
+		}
+	}
+
+	for _, in := range []string{`-1`, `"abc"`, `"1e3"`, `true`} {
+		var a Amount
+		if err := json.Unmarshal([]byte(in), &a); err == nil {
+			t.Fatalf("Unmarshal(%s) = %s, want error", in, a)
+		}
+	}
+}
@@ -1,36 +1,228 @@
 package blockchain
 
 import (
+	"crypto/ecdsa"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math/big"
 	"time"
+
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+// Версии формата заголовка блока. Хеш блока всегда вычисляется по правилам
+// его собственной версии, поэтому новый формат не меняет хеши старых блоков.
+const (
+	// BlockVersionLegacy — исходный формат: SHA-256 от строки fmt.Sprintf со
+	// всеми транзакциями; встречается только у ранее сохраненных блоков
+	BlockVersionLegacy uint32 = 0
+	// BlockVersionMerkle — каноничный двоичный заголовок с корнем дерева Меркла
+	BlockVersionMerkle uint32 = 1
+
+	// CurrentBlockVersion — версия, в которой создаются новые блоки
+	CurrentBlockVersion = BlockVersionMerkle
 )
 
+// ErrUnsupportedBlockVersion возвращается для блока неизвестной версии
+var ErrUnsupportedBlockVersion = errors.New("unsupported block version")
+
 // Block представляет собой блок в блокчейне
 type Block struct {
-	Index        int           `json:"index"`
-	Timestamp    string        `json:"timestamp"`
-	Transactions []Transaction `json:"transactions"` // Список транзакций в блоке
-	PrevHash     string        `json:"prevHash"`
-	Hash         string        `json:"hash"`
+	Version        uint32        `json:"version,omitempty"` // Версия формата заголовка
+	Index          int           `json:"index"`
+	Timestamp      string        `json:"timestamp"`
+	Transactions   []Transaction `json:"transactions"` // Список транзакций в блоке
+	PrevHash       string        `json:"prevHash"`
+	MerkleRoot     string        `json:"merkleRoot,omitempty"` // Корень дерева Меркла хешей транзакций
+	Hash           string        `json:"hash"`
+	Slot           uint64        `json:"slot,omitempty"`           // Номер слота, в котором создан блок
+	Proposer       string        `json:"proposer,omitempty"`       // Адрес валидатора, создавшего блок
+	ProposerPubKey string        `json:"proposerPubKey,omitempty"` // Публичный ключ валидатора, hex(X || Y)
+	Signature      string        `json:"signature,omitempty"`      // Подпись валидатора над хешем блока
+}
+
+// GenesisTimestamp — время генезиса сети разработки (DefaultGenesis)
+const GenesisTimestamp = "2025-01-01T00:00:00Z"
+
+// NewGenesisBlock создает генезис-блок сети разработки
+func NewGenesisBlock() Block {
+	return DefaultGenesis().Block()
 }
 
 func NewBlock(index int, transactions []Transaction, prevHash string) Block {
 	block := Block{
+		Version:      CurrentBlockVersion,
 		Index:        index,
 		Timestamp:    time.Now().Format(time.RFC3339),
 		Transactions: transactions,
 		PrevHash:     prevHash,
 	}
+	block.MerkleRoot = block.CalculateMerkleRoot()
 	block.Hash = block.CalculateHash()
 	return block
 }
 
+// CalculateHash вычисляет хеш блока по правилам его версии;
+// для неизвестной версии возвращает пустую строку
 func (b *Block) CalculateHash() string {
-	record := fmt.Sprintf("%d%s%v%s", b.Index, b.Timestamp, b.Transactions, b.PrevHash)
+	switch b.Version {
+	case BlockVersionLegacy:
+		return b.legacyHash()
+	case BlockVersionMerkle:
+		header := b.Header()
+		hash := header.Hash()
+		return hex.EncodeToString(hash[:])
+	default:
+		return ""
+	}
+}
+
+// legacyHash — хеш блока версии BlockVersionLegacy. Транзакции в нем
+// записываются через %v, поэтому хеш считается по замороженной структуре
+// legacyTransaction, а не по текущей Transaction.
+func (b *Block) legacyHash() string {
+	transactions := make([]legacyTransaction, len(b.Transactions))
+	for i := range b.Transactions {
+		transactions[i] = b.Transactions[i].legacyRecord()
+	}
+	record := fmt.Sprintf("%d%s%v%s", b.Index, b.Timestamp, transactions, b.PrevHash)
+	if b.Slot > 0 {
+		// Слот входит в хеш, чтобы его нельзя было подменить после подписи;
+		// у блоков, созданных до появления слотов, его нет, и их хеши не меняются
+		record += fmt.Sprintf("%d", b.Slot)
+	}
+	if b.Proposer != "" {
+		// Подпись не входит в хеш: она ставится над ним
+		record += b.Proposer + b.ProposerPubKey
+	}
 	h := sha256.New()
 	h.Write([]byte(record))
 	hashed := h.Sum(nil)
 	return hex.EncodeToString(hashed)
 }
+
+// Sign записывает в блок публичный ключ валидатора, пересчитывает хеш
+// и подписывает его приватным ключом валидатора. Отдельный идентификатор
+// сети не нужен: через PrevHash хеш блока ведет к генезис-блоку, который
+// фиксирует спецификацию сети вместе с ее ChainID.
+func (b *Block) Sign(privateKey *ecdsa.PrivateKey) error {
+	b.ProposerPubKey = encodePublicKey(&privateKey.PublicKey)
+	b.Hash = b.CalculateHash()
+
+	hash, err := hex.DecodeString(b.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to decode block hash: %w", err)
+	}
+
+	signature, err := signHash(privateKey, hash)
+	if err != nil {
+		return fmt.Errorf("failed to sign block: %w", err)
+	}
+	b.Signature = signature
+	return nil
+}
+
+// Verify проверяет подпись блока публичным ключом из ProposerPubKey
+func (b *Block) Verify() (bool, error) {
+	if b.Signature == "" {
+		return false, errors.New("block is not signed")
+	}
+
+	publicKey, err := decodePublicKey(b.ProposerPubKey)
+	if err != nil {
+		return false, err
+	}
+
+	signature, err := hex.DecodeString(b.Signature)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if len(signature) != 64 {
+		return false, errors.New("invalid signature length")
+	}
+
+	hash, err := hex.DecodeString(b.Hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode block hash: %w", err)
+	}
+
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	return ecdsa.Verify(publicKey, hash, r, s), nil
+}
+
+// encodePublicKey кодирует ключ как hex(X || Y), дополняя координаты до 32 байт
+func encodePublicKey(publicKey *ecdsa.PublicKey) string {
+	return hex.EncodeToString(crypto.MarshalPublicKey(publicKey))
+}
+
+// decodePublicKey разбирает ключ P-256, закодированный encodePublicKey;
+// короткие ключи старого формата здесь не принимаются
+func decodePublicKey(publicKeyHex string) (*ecdsa.PublicKey, error) {
+	data, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(data) != crypto.PublicKeySize {
+		return nil, errors.New("invalid public key length")
+	}
+	return crypto.UnmarshalPublicKey(data)
+}
+
+// BlockHeader — заголовок блока, который хешируется и подписывается.
+// Транзакции входят в него только через корень дерева Меркла.
+type BlockHeader struct {
+	Version        uint32 `json:"version"`
+	Index          uint64 `json:"index"`
+	Timestamp      string `json:"timestamp"`
+	PrevHash       string `json:"prevHash"`
+	MerkleRoot     string `json:"merkleRoot"`
+	Slot           uint64 `json:"slot"`
+	Proposer       string `json:"proposer"`
+	ProposerPubKey string `json:"proposerPubKey"`
+}
+
+// Header возвращает заголовок блока
+func (b *Block) Header() BlockHeader {
+	return BlockHeader{
+		Version:        b.Version,
+		Index:          uint64(b.Index),
+		Timestamp:      b.Timestamp,
+		PrevHash:       b.PrevHash,
+		MerkleRoot:     b.MerkleRoot,
+		Slot:           b.Slot,
+		Proposer:       b.Proposer,
+		ProposerPubKey: b.ProposerPubKey,
+	}
+}
+
+// Encode возвращает каноничную двоичную запись заголовка: версия (uint32)
+// и затем поля в фиксированном порядке; числа в big-endian, строки с
+// префиксом длины uint32. Версия идет первой, чтобы формат можно было
+// расширять без неоднозначности.
+func (h BlockHeader) Encode() []byte {
+	var buf []byte
+	buf = binary.BigEndian.AppendUint32(buf, h.Version)
+	buf = binary.BigEndian.AppendUint64(buf, h.Index)
+	buf = appendString(buf, h.Timestamp)
+	buf = appendString(buf, h.PrevHash)
+	buf = appendString(buf, h.MerkleRoot)
+	buf = binary.BigEndian.AppendUint64(buf, h.Slot)
+	buf = appendString(buf, h.Proposer)
+	buf = appendString(buf, h.ProposerPubKey)
+	return buf
+}
+
+// Hash возвращает SHA-256 от каноничной записи заголовка
+func (h BlockHeader) Hash() [32]byte {
+	return sha256.Sum256(h.Encode())
+}
+
+// appendString дописывает строку с префиксом длины
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(s)))
+	return append(buf, s...)
+}
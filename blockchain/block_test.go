@@ -0,0 +1,47 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/HHpCpp/AVAF/adb"
+)
+
+// Блоки базы, созданной до появления версий блоков: генезис без транзакций
+// и блок с переводом legacyTransactionJSON
+const (
+	legacyGenesisJSON = `{"index":0,"timestamp":"2026-10-17T18:28:27Z","transactions":[],"prevHash":"","hash":"6e2fbbd798f8185306ce48e8db778cdb2c43f5b4f14df4ed32e2cebac640be2d"}`
+	legacyBlockJSON   = `{"index":1,"timestamp":"2026-10-17T18:28:29Z","transactions":[` + legacyTransactionJSON + `],"prevHash":"6e2fbbd798f8185306ce48e8db778cdb2c43f5b4f14df4ed32e2cebac640be2d","hash":"3630134291521a8bacfdc9463b8ef94051a0f23ebd3c11a9e3408d1337a43d61"}`
+)
+
+func TestLegacyBlockHash(t *testing.T) {
+	for _, data := range []string{legacyGenesisJSON, legacyBlockJSON} {
+		var block Block
+		if err := json.Unmarshal([]byte(data), &block); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if block.Version != BlockVersionLegacy {
+			t.Fatalf("block %d version = %d, want %d", block.Index, block.Version, BlockVersionLegacy)
+		}
+		if got := block.CalculateHash(); got != block.Hash {
+			t.Fatalf("block %d hash = %s, want stored %s", block.Index, got, block.Hash)
+		}
+	}
+}
+
+func TestVerifyStoreLegacyChain(t *testing.T) {
+	db := adb.NewMemoryDB()
+	for key, value := range map[string]string{
+		"block_0": legacyGenesisJSON,
+		"block_1": legacyBlockJSON,
+		"account_AVAFu3f093cd146875230446bf546f4777196e5b2617e": `{"address":"AVAFu3f093cd146875230446bf546f4777196e5b2617e","publicKey":"` + legacySenderPublicKey + `"}`,
+	} {
+		if err := db.Save(key, []byte(value)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	if err := VerifyStore(db, DefaultGenesis(), 0, -1); err != nil {
+		t.Fatalf("VerifyStore: %v", err)
+	}
+}
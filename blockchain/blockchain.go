@@ -7,28 +7,33 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"strings"
+	"strconv"
+	"sync"
 	"time"
 
 	AA "github.com/HHpCpp/AVAF/accounts"
 	avafdb "github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
 	pos "github.com/HHpCpp/AVAF/pos"
 )
 
 type Blockchain struct {
+	mu             sync.RWMutex // Защищает Chain при фиксации блоков
 	Chain          []Block
 	AccountManager *AA.AccountManager
-	db             *avafdb.LevelDB // LevelDB для хранения данных
+	db             avafdb.KVStore // Хранилище данных (LevelDB или память)
 	StakingWallet  *pos.StakingWallet
+	Mempool        *Mempool    // Транзакции, ожидающие включения в блок
+	genesis        GenesisSpec // Начальное состояние и параметры сети
 }
 
 func (bc *Blockchain) NewTransaction(Address string, Address1 string, prv *ecdsa.PrivateKey, i int) {
 	panic("unimplemented")
 }
 
-func GetLastBlockIndex(db *avafdb.LevelDB) (int, error) {
-	// Создаем итератор для LevelDB
-	iter := db.NewIterator()
+func GetLastBlockIndex(db avafdb.KVStore) (int, error) {
+	// Итерируем только по ключам блоков
+	iter := db.NewPrefixIterator("block_")
 	defer iter.Release()
 
 	lastIndex := -1
@@ -37,19 +42,16 @@ func GetLastBlockIndex(db *avafdb.LevelDB) (int, error) {
 	for iter.Next() {
 		key := string(iter.Key())
 
-		// Проверяем, что ключ начинается с "block_"
-		if strings.HasPrefix(key, "block_") {
-			// Извлекаем индекс из ключа
-			var index int
-			_, err := fmt.Sscanf(key, "block_%d", &index)
-			if err != nil {
-				return -1, fmt.Errorf("failed to parse block index: %w", err)
-			}
+		// Извлекаем индекс из ключа
+		var index int
+		_, err := fmt.Sscanf(key, "block_%d", &index)
+		if err != nil {
+			return -1, fmt.Errorf("failed to parse block index: %w", err)
+		}
 
-			// Обновляем последний индекс
-			if index > lastIndex {
-				lastIndex = index
-			}
+		// Обновляем последний индекс
+		if index > lastIndex {
+			lastIndex = index
 		}
 	}
 
@@ -60,53 +62,127 @@ func GetLastBlockIndex(db *avafdb.LevelDB) (int, error) {
 	return lastIndex, nil
 }
 
-func NewBlockchain(db *avafdb.LevelDB) (*Blockchain, error) {
-	// Создаем AccountManager
-	accountManager := AA.NewAccountManager(db)
-
-	// Создаем StakingWallet
-	stakingWallet := pos.NewStakingWallet(db)
-	/* if err := stakingWallet.LoadStakes(); err != nil {
-		return nil, fmt.Errorf("failed to load stakes: %w", err)
-	} */
+var (
+	ErrGenesisMismatch       = errors.New("stored genesis block does not match configured genesis")
+	ErrChainCorrupted        = errors.New("stored chain is corrupted")
+	ErrBlockNotNext          = errors.New("block does not extend the current chain")
+	ErrInvalidBlockHash      = errors.New("block hash mismatch")
+	ErrUnsignedBlock         = errors.New("block is not signed by a proposer")
+	ErrInvalidBlockSignature = errors.New("invalid block signature")
+)
 
-	// Создаем генезис-блок
-	genesisBlock := NewBlock(0, []Transaction{}, "")
-	chain := []Block{genesisBlock}
+// NewBlockchain открывает цепочку сети разработки (DefaultGenesis)
+func NewBlockchain(db avafdb.KVStore) (*Blockchain, error) {
+	return NewBlockchainWithGenesis(db, DefaultGenesis())
+}
 
-	// Сохраняем генезис-блок в LevelDB
-	if err := saveBlock(db, genesisBlock); err != nil {
-		return nil, fmt.Errorf("failed to save genesis block: %w", err)
+// NewBlockchainWithGenesis открывает цепочку с заданной спецификацией генезиса.
+// Для пустой базы записывает генезис-блок и начальное состояние, иначе
+// проверяет, что сохраненная цепочка создана из этой же спецификации.
+func NewBlockchainWithGenesis(db avafdb.KVStore, genesis GenesisSpec) (*Blockchain, error) {
+	if err := genesis.Validate(); err != nil {
+		return nil, err
 	}
 
-	return &Blockchain{
-		Chain:          chain,
+	// Один AccountManager на цепочку: его блокировка сериализует
+	// все изменения аккаунтов, включая стейкинг
+	accountManager := AA.NewAccountManager(db)
+
+	bc := &Blockchain{
 		AccountManager: accountManager,
-		StakingWallet:  stakingWallet,
+		StakingWallet:  pos.NewStakingWallet(db, accountManager),
+		Mempool:        NewMempool(DefaultMempoolConfig()),
+		genesis:        genesis,
 		db:             db,
-	}, nil
+	}
+
+	// Восстанавливаем цепочку из LevelDB или создаем генезис-блок
+	chain, err := bc.loadChain()
+	if err != nil {
+		return nil, err
+	}
+	bc.Chain = chain
+	return bc, nil
 }
 
-func LoadAllBlocks(db *avafdb.LevelDB) ([]Block, error) {
+// loadChain загружает сохраненную цепочку и проверяет ее генезис-блок и связность.
+// Для пустой базы сохраняет генезис-блок вместе с начальным состоянием.
+func (bc *Blockchain) loadChain() ([]Block, error) {
+	db := bc.db
+
+	blocks, err := LoadAllBlocks(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored blocks: %w", err)
+	}
+
+	if len(blocks) == 0 {
+		// Генезис-блок, начальные балансы и стейки записываются одним пакетом
+		if err := bc.AccountManager.Update(bc.stageGenesis); err != nil {
+			return nil, fmt.Errorf("failed to save genesis block: %w", err)
+		}
+		return []Block{bc.genesis.Block()}, nil
+	}
+
+	if blocks[0].Index != 0 {
+		return nil, fmt.Errorf("%w: genesis block is missing", ErrChainCorrupted)
+	}
+	if err := bc.checkGenesis(blocks[0]); err != nil {
+		return nil, err
+	}
+
+	// Проверяем, что в цепочке нет пропусков и каждый блок ссылается на предыдущий
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].Index != i {
+			return nil, fmt.Errorf("%w: expected block %d, found block %d", ErrChainCorrupted, i, blocks[i].Index)
+		}
+		if blocks[i].PrevHash != blocks[i-1].Hash {
+			return nil, fmt.Errorf("%w: block %d does not link to block %d", ErrChainCorrupted, i, i-1)
+		}
+	}
+
+	if err := indexBlocks(db, blocks); err != nil {
+		return nil, fmt.Errorf("failed to index stored blocks: %w", err)
+	}
+	if err := bc.recordValidatorSet(blocks[len(blocks)-1]); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// recordValidatorSet сохраняет набор валидаторов последнего блока базы,
+// созданной до появления наборов: им становится текущая таблица стейков
+func (bc *Blockchain) recordValidatorSet(tip Block) error {
+	_, err := bc.StakingWallet.ValidatorSet(tip.Index)
+	if !errors.Is(err, pos.ErrNoValidatorSet) {
+		return err
+	}
+
+	validators, err := bc.StakingWallet.Stakes()
+	if err != nil {
+		return err
+	}
+	return bc.AccountManager.Update(func(batch *avafdb.Batch) error {
+		return bc.StakingWallet.PutValidatorSet(batch, tip.Index, validators)
+	})
+}
+
+func LoadAllBlocks(db avafdb.KVStore) ([]Block, error) {
 	var blocks []Block
 
-	// Создаем итератор для LevelDB
-	iter := db.NewIterator()
+	// Итерируем только по ключам блоков
+	iter := db.NewPrefixIterator("block_")
 	defer iter.Release()
 
-	// Проходим по всем ключам
+	// Проходим по всем блокам
 	for iter.Next() {
-		key := string(iter.Key())
 		value := iter.Value()
 
-		// Проверяем, что ключ начинается с "block_"
-		if strings.HasPrefix(key, "block_") {
-			var block Block
-			if err := json.Unmarshal(value, &block); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal block: %w", err)
-			}
-			blocks = append(blocks, block)
+		var block Block
+		if err := json.Unmarshal(value, &block); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal block: %w", err)
 		}
+		blocks = append(blocks, block)
 	}
 
 	if err := iter.Error(); err != nil {
@@ -121,38 +197,133 @@ func LoadAllBlocks(db *avafdb.LevelDB) ([]Block, error) {
 	return blocks, nil
 }
 
-// saveBlock сохраняет блок в LevelDB
-func saveBlock(db *avafdb.LevelDB, block Block) error {
+// saveBlock сохраняет блок в LevelDB вместе с его вторичными индексами
+func saveBlock(db avafdb.KVStore, block Block) error {
+	return avafdb.Update(db, func(batch *avafdb.Batch) error {
+		return putBlock(batch, block)
+	})
+}
+
+// putBlock добавляет блок и его вторичные индексы в пакет изменений
+func putBlock(batch *avafdb.Batch, block Block) error {
 	data, err := json.Marshal(block)
 	if err != nil {
 		return fmt.Errorf("failed to marshal block: %w", err)
 	}
+
+	entries, err := blockIndexEntries(block)
+	if err != nil {
+		return err
+	}
 	key := fmt.Sprintf("block_%d", block.Index) // Уникальный ключ для каждого блока
-	return db.Save(key, data)
+
+	batch.Save(key, data)
+	for indexKey, value := range entries {
+		batch.Save(indexKey, value)
+	}
+	return nil
+}
+
+// blockHashKey возвращает ключ вторичного индекса блока по хешу
+func blockHashKey(hash string) string {
+	return "blockhash_" + hash
 }
 
-func NewTransaction(sender string, recipient string, amount float64, data string) (*Transaction, error) {
+// blockIndexEntries возвращает записи вторичных индексов блока:
+// хеш блока -> номер блока, хеш транзакции -> место ее включения
+// и история транзакций отправителя и получателя
+func blockIndexEntries(block Block) (map[string][]byte, error) {
+	entries := map[string][]byte{
+		blockHashKey(block.Hash): []byte(strconv.Itoa(block.Index)),
+	}
+
+	for i, tx := range block.Transactions {
+		data, err := json.Marshal(TransactionRecord{
+			Transaction: tx,
+			BlockIndex:  block.Index,
+			BlockHash:   block.Hash,
+			Position:    i,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal transaction record: %w", err)
+		}
+		entries[transactionKey(tx.Hash)] = data
+		entries[addressHistoryKey(tx.Sender, block.Index, i)] = []byte(tx.Hash)
+		entries[addressHistoryKey(tx.Recipient, block.Index, i)] = []byte(tx.Hash)
+	}
+
+	return entries, nil
+}
+
+// indexVersionKey хранит версию вторичных индексов в базе
+const indexVersionKey = "indexversion"
+
+// currentIndexVersion — версия набора индексов, которые записывает putBlock.
+// При добавлении нового индекса ее нужно увеличить, чтобы indexBlocks
+// один раз дописал его для уже сохраненных блоков.
+const currentIndexVersion = 1
+
+// putIndexVersion добавляет в пакет отметку о том, что индексы текущей версии записаны
+func putIndexVersion(batch *avafdb.Batch) {
+	batch.Save(indexVersionKey, []byte(strconv.Itoa(currentIndexVersion)))
+}
+
+// indexBlocks дописывает вторичные индексы для блоков, сохраненных до их
+// появления. Обход выполняется один раз: после него в базе сохраняется
+// версия индексов, и при следующих запусках индексы не перечитываются.
+func indexBlocks(db avafdb.KVStore, blocks []Block) error {
+	data, err := db.Load(indexVersionKey)
+	if err == nil {
+		version, err := strconv.Atoi(string(data))
+		if err != nil {
+			return fmt.Errorf("%w: invalid index version %q", ErrChainCorrupted, data)
+		}
+		if version >= currentIndexVersion {
+			return nil
+		}
+	} else if !errors.Is(err, avafdb.ErrNotFound) {
+		return fmt.Errorf("failed to read index version: %w", err)
+	}
+
+	batch := db.NewBatch()
+	for _, block := range blocks {
+		entries, err := blockIndexEntries(block)
+		if err != nil {
+			return err
+		}
+		for key, value := range entries {
+			if _, err := db.Load(key); err == nil {
+				continue
+			} else if !errors.Is(err, avafdb.ErrNotFound) {
+				return fmt.Errorf("failed to read index %s: %w", key, err)
+			}
+			batch.Save(key, value)
+		}
+	}
+	putIndexVersion(batch)
+	return db.Write(batch)
+}
+
+func NewTransaction(chainID string, sender string, recipient string, value amount.Amount, nonce uint64, data string) (*Transaction, error) {
 	if sender == recipient {
 		return nil, errors.New("sender and recipient cannot be the same")
 	}
 
-	if amount <= 0 {
+	if value.IsZero() {
 		return nil, errors.New("amount must be greater than 0")
 	}
 
-	// Стандартные значения комиссии
-	afuel := 1000.0
-	afuelPrice := 0.0001
-
 	tx := &Transaction{
-		Type:       "transfer",
+		ChainID:    chainID,
+		Type:       TxTypeTransfer,
 		Sender:     sender,
 		Recipient:  recipient,
 		ValueType:  "AVAF",
-		Value:      amount,
-		Afuel:      afuel,
-		AfuelPrice: afuelPrice,
+		Value:      value,
+		Afuel:      DefaultAfuel,
+		AfuelPrice: DefaultAfuelPrice,
 		Data:       data,
+		Nonce:      nonce,
 		Timestamp:  time.Now().Format(time.RFC3339), // Добавляем текущее время
 	}
 
@@ -161,30 +332,66 @@ func NewTransaction(sender string, recipient string, amount float64, data string
 	tx.Hash = hex.EncodeToString(hash[:])
 	return tx, nil
 }
-func (bc *Blockchain) CreateTransaction(sender string, recipient string, privateKey *ecdsa.PrivateKey, amount float64, data string) (*Transaction, error) {
+func (bc *Blockchain) CreateTransaction(sender string, recipient string, privateKey *ecdsa.PrivateKey, value amount.Amount, data string) (*Transaction, error) {
+	return bc.createTransaction(TxTypeTransfer, sender, recipient, privateKey, value, data)
+}
+
+// StakeTokens создает транзакцию стейкинга и передает ее в пул. Сумма
+// списывается с баланса и добавляется к стейку аккаунта, когда транзакция
+// включается в блок; производителей следующих блоков выбирают с учетом нового стейка.
+func (bc *Blockchain) StakeTokens(address string, value amount.Amount, privateKey *ecdsa.PrivateKey) (*Transaction, error) {
+	if privateKey == nil {
+		return nil, fmt.Errorf("private key is required for staking")
+	}
+	return bc.createTransaction(TxTypeStake, address, pos.StakingAddress, privateKey, value, "")
+}
+
+// createTransaction создает, подписывает и передает в пул транзакцию типа txType
+func (bc *Blockchain) createTransaction(txType, sender, recipient string, privateKey *ecdsa.PrivateKey, value amount.Amount, data string) (*Transaction, error) {
 	// Проверяем, что отправитель и получатель не совпадают
 	if sender == recipient {
 		return nil, fmt.Errorf("sender and recipient cannot be the same")
 	}
 
+	// Следующий nonce отправителя защищает транзакцию от повторной отправки;
+	// ожидающие в пуле транзакции отправителя уже заняли свои nonce
+	accountNonce, err := bc.AccountManager.GetNonce(sender)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender nonce: %w", err)
+	}
+	nonce := bc.Mempool.NextNonce(sender, accountNonce)
+
+	// Создаем транзакцию
+	tx, err := NewTransaction(bc.genesis.ChainID, sender, recipient, value, nonce, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	tx.Type = txType
+
+	// Комиссия по параметрам сети из генезиса
+	tx.Afuel = bc.genesis.Fees.Afuel
+	tx.AfuelPrice = bc.genesis.Fees.MinAfuelPrice
+	hash := tx.Hashdo()
+	tx.Hash = hex.EncodeToString(hash[:])
+
 	// Получаем баланс отправителя
 	sb, err := bc.AccountManager.GetBalance(sender)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sender balance: %w", err)
 	}
 
-	// Рассчитываем комиссию (1000 Afuel * 0.0001 AVAF)
-	commission := 1000.0 * 0.0001
-
 	// Проверяем, что у отправителя достаточно средств (сумма + комиссия)
-	if sb["AVAF"] < amount+commission {
-		return nil, fmt.Errorf("insufficient balance: sender has %.2f, required %.2f", sb["AVAF"], amount+commission)
+	fee, err := tx.Fee()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate fee: %w", err)
 	}
-
-	// Создаем транзакцию
-	tx, err := NewTransaction(sender, recipient, amount, data)
+	required, err := value.Add(fee)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create transaction: %w", err)
+		return nil, fmt.Errorf("failed to calculate required balance: %w", err)
+	}
+	if sb["AVAF"] < required {
+		return nil, fmt.Errorf("insufficient balance: sender has %s, required %s", sb["AVAF"], required)
 	}
 
 	// Подписываем транзакцию с использованием приватного ключа
@@ -202,29 +409,27 @@ func (bc *Blockchain) CreateTransaction(sender string, recipient string, private
 		return nil, fmt.Errorf("invalid transaction signature")
 	}
 
-	// Добавляем транзакцию в новый блок
-	bc.AddBlock([]Transaction{*tx})
+	// Передаем транзакцию в пул; в блок она попадет при следующей сборке
+	if err := bc.SubmitTransaction(*tx); err != nil {
+		return nil, fmt.Errorf("failed to submit transaction: %w", err)
+	}
 
 	return tx, nil
 }
 
 func (bc *Blockchain) GetBlockByHash(hash string) (*Block, error) {
-	// Формируем ключ для блока
-	key := fmt.Sprintf("block_%s", hash)
-
-	// Загружаем данные из LevelDB
-	data, err := bc.db.Load(key)
+	// Находим номер блока по индексу хешей
+	data, err := bc.db.Load(blockHashKey(hash))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load block: %w", err)
+		return nil, fmt.Errorf("failed to find block %s: %w", hash, err)
 	}
 
-	// Десериализуем данные в структуру Block
-	var block Block
-	if err := json.Unmarshal(data, &block); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal block: %w", err)
+	index, err := strconv.Atoi(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block index: %w", err)
 	}
 
-	return &block, nil
+	return bc.GetBlockByIndex(index)
 }
 
 func (bc *Blockchain) GetBlockByIndex(index int) (*Block, error) {
@@ -246,6 +451,9 @@ func (bc *Blockchain) GetBlockByIndex(index int) (*Block, error) {
 	return &block, nil
 }
 func (bc *Blockchain) GetCurrentBlock() (*Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
 	// Проверяем, что блокчейн не пустой
 	if len(bc.Chain) == 0 {
 		return nil, fmt.Errorf("blockchain is empty")
@@ -259,23 +467,19 @@ func (bc *Blockchain) GetCurrentBlock() (*Block, error) {
 func (bc *Blockchain) GetAllBlocks() ([]Block, error) {
 	var blocks []Block
 
-	// Создаем итератор для LevelDB
-	iter := bc.db.NewIterator()
+	// Итерируем только по ключам блоков
+	iter := bc.db.NewPrefixIterator("block_")
 	defer iter.Release()
 
-	// Проходим по всем ключам
+	// Проходим по всем блокам
 	for iter.Next() {
-		key := string(iter.Key())
 		value := iter.Value()
 
-		// Проверяем, что ключ начинается с "block_"
-		if strings.HasPrefix(key, "block_") {
-			var block Block
-			if err := json.Unmarshal(value, &block); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal block: %w", err)
-			}
-			blocks = append(blocks, block)
+		var block Block
+		if err := json.Unmarshal(value, &block); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal block: %w", err)
 		}
+		blocks = append(blocks, block)
 	}
 
 	if err := iter.Error(); err != nil {
@@ -295,7 +499,7 @@ func (bc *Blockchain) GetAccountBalance(sender string) (any, any) {
 }
 
 // loadBlock загружает блок из LevelDB
-func LoadBlock(db *avafdb.LevelDB, index int) (Block, error) {
+func LoadBlock(db avafdb.KVStore, index int) (Block, error) {
 	key := fmt.Sprintf("block_%d", index)
 	data, err := db.Load(key)
 	if err != nil {
@@ -310,69 +514,220 @@ func LoadBlock(db *avafdb.LevelDB, index int) (Block, error) {
 	return block, nil
 }
 
-// AddBlock добавляет новый блок в блокчейн
+// AddBlock добавляет новый блок в блокчейн. Блок подписывается валидатором
+// текущего слота, поэтому его ключ должен быть зарегистрирован на этом узле.
 func (bc *Blockchain) AddBlock(transactions []Transaction) error {
-	// Получаем последний блок
-	prevBlock := bc.Chain[len(bc.Chain)-1]
+	assignment, err := bc.nextSlot()
+	if err != nil {
+		return err
+	}
+
+	_, err = bc.sealBlock(assignment, transactions)
+	return err
+}
+
+// verifyBlockSignature проверяет, что блок подписан ключом аккаунта Proposer
+func (bc *Blockchain) verifyBlockSignature(block Block) error {
+	if block.Proposer == "" || block.ProposerPubKey == "" || block.Signature == "" {
+		return fmt.Errorf("%w: block %d", ErrUnsignedBlock, block.Index)
+	}
+
+	// Ключ в блоке должен принадлежать адресу валидатора
+	publicKey, err := bc.AccountManager.GetPublicKey(block.Proposer)
+	if err != nil {
+		return fmt.Errorf("failed to get proposer public key: %w", err)
+	}
+	if encodePublicKey(publicKey) != block.ProposerPubKey {
+		return fmt.Errorf("%w: block %d public key does not belong to %s", ErrInvalidBlockSignature, block.Index, block.Proposer)
+	}
 
-	// Создаем новый блок с индексом на 1 больше, чем у предыдущего
-	newBlock := NewBlock(prevBlock.Index+1, transactions, prevBlock.Hash)
+	valid, err := block.Verify()
+	if err != nil {
+		return fmt.Errorf("%w: block %d: %v", ErrInvalidBlockSignature, block.Index, err)
+	}
+	if !valid {
+		return fmt.Errorf("%w: block %d", ErrInvalidBlockSignature, block.Index)
+	}
+	return nil
+}
+
+// CommitBlock проверяет, что блок продолжает цепочку, применяет его переводы
+// и сохраняет блок
+func (bc *Blockchain) CommitBlock(newBlock Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	prevBlock := bc.Chain[len(bc.Chain)-1]
+	if newBlock.Index != prevBlock.Index+1 {
+		return fmt.Errorf("%w: expected block %d, got %d", ErrBlockNotNext, prevBlock.Index+1, newBlock.Index)
+	}
+	if newBlock.PrevHash != prevBlock.Hash {
+		return fmt.Errorf("%w: block %d does not link to %s", ErrBlockNotNext, newBlock.Index, prevBlock.Hash)
+	}
+	// Старый формат допустим только для уже сохраненных блоков: у него
+	// нет корня Меркла, и включение его транзакций нельзя доказать
+	if newBlock.Version != CurrentBlockVersion {
+		return fmt.Errorf("%w: block %d has version %d, new blocks must use version %d",
+			ErrUnsupportedBlockVersion, newBlock.Index, newBlock.Version, CurrentBlockVersion)
+	}
+	if err := newBlock.checkHash(); err != nil {
+		return err
+	}
+	if err := bc.verifyBlockSignature(newBlock); err != nil {
+		return err
+	}
+	if err := bc.verifyProposer(newBlock, prevBlock, time.Now()); err != nil {
+		return err
+	}
 
-	// Сохраняем блок в LevelDB
-	if err := saveBlock(bc.db, newBlock); err != nil {
-		return fmt.Errorf("failed to save block: %w", err)
+	// Балансы, блок и его индексы записываются одним пакетом под блокировкой
+	// аккаунтов; блок с недопустимым переводом отклоняется целиком
+	err := bc.AccountManager.Update(func(batch *avafdb.Batch) error {
+		state, err := bc.stageTransactions(batch, prevBlock.Index, newBlock.Transactions)
+		if err != nil {
+			return fmt.Errorf("failed to apply block %d: %w", newBlock.Index, err)
+		}
+		// Набор валидаторов после блока: из него выбирается производитель следующего
+		validators, err := state.validatorSet()
+		if err != nil {
+			return err
+		}
+		if err := bc.StakingWallet.PutValidatorSet(batch, newBlock.Index, validators); err != nil {
+			return err
+		}
+		if err := putBlock(batch, newBlock); err != nil {
+			return fmt.Errorf("failed to save block: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Добавляем блок в цепочку
 	bc.Chain = append(bc.Chain, newBlock)
+
+	// Включенные транзакции больше не ожидают
+	bc.Mempool.RemoveIncluded(newBlock.Transactions)
 	return nil
 }
 
-func (bc *Blockchain) ValidateTransaction(tx Transaction) bool {
+// ValidateTransaction проверяет подпись, хеш и nonce транзакции относительно текущего состояния
+func (bc *Blockchain) ValidateTransaction(tx Transaction) error {
+	if err := bc.verifyTransaction(tx); err != nil {
+		return err
+	}
+
+	// Verify the sender's nonce
+	nonce, err := bc.AccountManager.GetNonce(tx.Sender)
+	if err != nil {
+		return fmt.Errorf("failed to get sender nonce: %w", err)
+	}
+	return checkNonce(tx, nonce)
+}
+
+// verifyTransaction проверяет подпись и хеш транзакции и то, что она еще не включена в цепочку
+func (bc *Blockchain) verifyTransaction(tx Transaction) error {
+	if err := checkChainID(tx, bc.genesis.ChainID); err != nil {
+		return err
+	}
+	if err := bc.verifyTransactionSignature(tx); err != nil {
+		return err
+	}
+
+	// Транзакция, уже включенная в цепочку, не может быть принята повторно
+	return bc.checkNotCommitted(tx.Hash)
+}
+
+// verifyTransactionSignature проверяет подпись транзакции ключом отправителя и ее хеш
+func (bc *Blockchain) verifyTransactionSignature(tx Transaction) error {
 	// Retrieve the sender's public key
 	publicKey, err := bc.AccountManager.GetPublicKey(tx.Sender)
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to get sender public key: %w", err)
 	}
 
 	// Verify the transaction's signature
 	valid, err := tx.Verify(publicKey)
-	if err != nil || !valid {
-		return false
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !valid {
+		return ErrInvalidSignature
 	}
 
 	// Verify the transaction's hash
 	computedHash := tx.Hashdo()
 	computedHashStr := hex.EncodeToString(computedHash[:])
 	if computedHashStr != tx.Hash {
-		return computedHashStr == tx.Hash
+		return fmt.Errorf("%w: expected %s, got %s", ErrInvalidHash, computedHashStr, tx.Hash)
 	}
+	return nil
+}
+
+// checkNotCommitted возвращает ErrDuplicateTransaction, если транзакция уже есть в цепочке
+func (bc *Blockchain) checkNotCommitted(hash string) error {
+	_, err := bc.db.Load(transactionKey(hash))
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrDuplicateTransaction, hash)
+	}
+	if !errors.Is(err, avafdb.ErrNotFound) {
+		return fmt.Errorf("failed to check transaction index: %w", err)
+	}
+	return nil
+}
+
+// TransactionRecord описывает транзакцию и место ее включения в цепочку
+type TransactionRecord struct {
+	Transaction Transaction `json:"transaction"`
+	BlockIndex  int         `json:"blockIndex"`
+	BlockHash   string      `json:"blockHash"`
+	Position    int         `json:"position"` // Порядковый номер транзакции в блоке
+}
 
-	return true
+// transactionKey возвращает ключ индекса транзакции по хешу
+func transactionKey(hash string) string {
+	return fmt.Sprintf("tx_%s", hash)
 }
 
-// saveTransaction сохраняет транзакцию в LevelDB
-func SaveTransaction(db *avafdb.LevelDB, tx Transaction) error {
-	data, err := json.Marshal(tx)
+// SaveTransaction сохраняет запись о включенной в блок транзакции в LevelDB
+func SaveTransaction(db avafdb.KVStore, record TransactionRecord) error {
+	data, err := json.Marshal(record)
 	if err != nil {
 		return fmt.Errorf("failed to marshal transaction: %w", err)
 	}
-	key := fmt.Sprintf("tx_%s", tx.Hash)
-	return db.Save(key, data)
+	return db.Save(transactionKey(record.Transaction.Hash), data)
 }
 
-// loadTransaction загружает транзакцию из LevelDB
-func LoadTransaction(db *avafdb.LevelDB, hash string) (Transaction, error) {
-	key := fmt.Sprintf("tx_%s", hash)
-	data, err := db.Load(key)
+// LoadTransactionRecord загружает транзакцию вместе с данными о блоке из LevelDB
+func LoadTransactionRecord(db avafdb.KVStore, hash string) (TransactionRecord, error) {
+	data, err := db.Load(transactionKey(hash))
 	if err != nil {
-		return Transaction{}, fmt.Errorf("failed to load transaction: %w", err)
+		return TransactionRecord{}, fmt.Errorf("failed to load transaction: %w", err)
 	}
 
-	var tx Transaction
-	if err := json.Unmarshal(data, &tx); err != nil {
-		return Transaction{}, fmt.Errorf("failed to unmarshal transaction: %w", err)
+	var record TransactionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return TransactionRecord{}, fmt.Errorf("failed to unmarshal transaction: %w", err)
 	}
 
-	return tx, nil
+	return record, nil
+}
+
+// LoadTransaction загружает транзакцию из LevelDB
+func LoadTransaction(db avafdb.KVStore, hash string) (Transaction, error) {
+	record, err := LoadTransactionRecord(db, hash)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return record.Transaction, nil
+}
+
+// GetTransactionByHash возвращает включенную в цепочку транзакцию по ее хешу
+func (bc *Blockchain) GetTransactionByHash(hash string) (*TransactionRecord, error) {
+	record, err := LoadTransactionRecord(bc.db, hash)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
 }
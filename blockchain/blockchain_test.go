@@ -0,0 +1,333 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/HHpCpp/AVAF/accounts"
+	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+	"github.com/HHpCpp/AVAF/pos"
+)
+
+// testAccount — аккаунт генезиса тестовой цепочки и его ключ
+type testAccount struct {
+	address string
+	key     *ecdsa.PrivateKey
+}
+
+func newTestAccount(t *testing.T) testAccount {
+	t.Helper()
+	key, address, err := accounts.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	return testAccount{address: address, key: key}
+}
+
+// newTestChain создает цепочку в памяти, в генезисе которой validator —
+// единственный валидатор, а у обоих аккаунтов есть баланс
+func newTestChain(t *testing.T, validator, user testAccount) *Blockchain {
+	t.Helper()
+
+	genesis := DefaultGenesis()
+	for _, account := range []testAccount{validator, user} {
+		genesis.Alloc = append(genesis.Alloc, GenesisAlloc{
+			Address:   account.address,
+			PublicKey: hex.EncodeToString(crypto.MarshalPublicKey(&account.key.PublicKey)),
+			Balance:   amount.MustParse("100"),
+		})
+	}
+	genesis.Validators = []GenesisStake{{Address: validator.address, Stake: amount.MustParse("10")}}
+
+	bc, err := NewBlockchainWithGenesis(adb.NewMemoryDB(), genesis)
+	if err != nil {
+		t.Fatalf("NewBlockchainWithGenesis: %v", err)
+	}
+	return bc
+}
+
+// commitTestBlock подписывает блок следующего слота ключом validator и
+// передает его в CommitBlock
+func commitTestBlock(t *testing.T, bc *Blockchain, validator testAccount, transactions []Transaction) error {
+	t.Helper()
+
+	prevBlock, err := bc.GetCurrentBlock()
+	if err != nil {
+		t.Fatalf("GetCurrentBlock: %v", err)
+	}
+	_, err = bc.sealBlock(slotAssignment{
+		prevBlock:  *prevBlock,
+		slot:       prevBlock.Slot + 1,
+		proposer:   validator.address,
+		privateKey: validator.key,
+	}, transactions)
+	return err
+}
+
+// signedTransaction создает транзакцию типа txType из from в to,
+// подписанную key
+func signedTransaction(t *testing.T, chainID, txType, from, to string, key *ecdsa.PrivateKey, nonce uint64) Transaction {
+	t.Helper()
+
+	tx, err := NewTransaction(chainID, from, to, amount.MustParse("1"), nonce, "")
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	tx.Type = txType
+	hash := tx.Hashdo()
+	tx.Hash = hex.EncodeToString(hash[:])
+	if err := tx.Sign(key); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return *tx
+}
+
+// signedTransfer создает подписанный key перевод из from в to
+func signedTransfer(t *testing.T, chainID, from, to string, key *ecdsa.PrivateKey, nonce uint64) Transaction {
+	t.Helper()
+	return signedTransaction(t, chainID, TxTypeTransfer, from, to, key, nonce)
+}
+
+// assertRejected проверяет, что блок отклонен с ошибкой want, а цепочка
+// и балансы остались прежними
+func assertRejected(t *testing.T, bc *Blockchain, err, want error, height int, balances map[string]amount.Amount) {
+	t.Helper()
+
+	if !errors.Is(err, want) {
+		t.Fatalf("CommitBlock error = %v, want %v", err, want)
+	}
+	if got := len(bc.Chain); got != height {
+		t.Fatalf("chain height = %d, want %d", got, height)
+	}
+	for address, want := range balances {
+		balance, err := bc.AccountManager.GetBalance(address)
+		if err != nil {
+			t.Fatalf("GetBalance: %v", err)
+		}
+		if balance[FeeCurrency] != want {
+			t.Fatalf("balance of %s = %s, want %s", address, balance[FeeCurrency], want)
+		}
+	}
+}
+
+func TestCommitBlockRejectsForgedTransaction(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+	attacker := newTestAccount(t)
+
+	// Перевод от имени user, подписанный чужим ключом
+	tx := signedTransfer(t, bc.Genesis().ChainID, user.address, validator.address, attacker.key, 0)
+
+	err := commitTestBlock(t, bc, validator, []Transaction{tx})
+	assertRejected(t, bc, err, ErrInvalidSignature, 1, map[string]amount.Amount{
+		user.address:      amount.MustParse("100"),
+		validator.address: amount.MustParse("100"),
+	})
+}
+
+func TestCommitBlockRejectsTamperedTransaction(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	// Сумма изменена после подписи
+	tx := signedTransfer(t, bc.Genesis().ChainID, user.address, validator.address, user.key, 0)
+	tx.Value = amount.MustParse("50")
+
+	err := commitTestBlock(t, bc, validator, []Transaction{tx})
+	assertRejected(t, bc, err, ErrInvalidSignature, 1, map[string]amount.Amount{
+		user.address:      amount.MustParse("100"),
+		validator.address: amount.MustParse("100"),
+	})
+}
+
+func TestCommitBlockRejectsReplayedTransaction(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	tx := signedTransfer(t, bc.Genesis().ChainID, user.address, validator.address, user.key, 0)
+	if err := commitTestBlock(t, bc, validator, []Transaction{tx}); err != nil {
+		t.Fatalf("first block: %v", err)
+	}
+
+	userBalance, _ := bc.AccountManager.GetBalance(user.address)
+	validatorBalance, _ := bc.AccountManager.GetBalance(validator.address)
+
+	err := commitTestBlock(t, bc, validator, []Transaction{tx})
+	assertRejected(t, bc, err, ErrDuplicateTransaction, 2, map[string]amount.Amount{
+		user.address:      userBalance[FeeCurrency],
+		validator.address: validatorBalance[FeeCurrency],
+	})
+}
+
+func TestCommitBlockRejectsDuplicateInBlock(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	tx := signedTransfer(t, bc.Genesis().ChainID, user.address, validator.address, user.key, 0)
+
+	err := commitTestBlock(t, bc, validator, []Transaction{tx, tx})
+	assertRejected(t, bc, err, ErrDuplicateTransaction, 1, map[string]amount.Amount{
+		user.address:      amount.MustParse("100"),
+		validator.address: amount.MustParse("100"),
+	})
+}
+
+func TestCommitBlockRejectsWrongNonce(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+	chainID := bc.Genesis().ChainID
+
+	tx := signedTransfer(t, chainID, user.address, validator.address, user.key, 0)
+	if err := commitTestBlock(t, bc, validator, []Transaction{tx}); err != nil {
+		t.Fatalf("first block: %v", err)
+	}
+	userBalance, _ := bc.AccountManager.GetBalance(user.address)
+	balances := map[string]amount.Amount{user.address: userBalance[FeeCurrency]}
+
+	// Другая транзакция с уже использованным nonce
+	stale := signedTransfer(t, chainID, user.address, newTestAccount(t).address, user.key, 0)
+	err := commitTestBlock(t, bc, validator, []Transaction{stale})
+	assertRejected(t, bc, err, ErrStaleNonce, 2, balances)
+
+	// Nonce с пропуском: транзакция 1 еще не включена
+	gap := signedTransfer(t, chainID, user.address, validator.address, user.key, 2)
+	err = commitTestBlock(t, bc, validator, []Transaction{gap})
+	assertRejected(t, bc, err, ErrNonceGap, 2, balances)
+
+	// Обе транзакции подряд в одном блоке принимаются
+	next := signedTransfer(t, chainID, user.address, validator.address, user.key, 1)
+	if err := commitTestBlock(t, bc, validator, []Transaction{next, gap}); err != nil {
+		t.Fatalf("block with consecutive nonces: %v", err)
+	}
+}
+
+func TestCommitBlockRejectsWrongChainTransaction(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	// Подпись верна, но транзакция предназначена другой сети
+	tx := signedTransfer(t, "avaf-othernet", user.address, validator.address, user.key, 0)
+
+	err := commitTestBlock(t, bc, validator, []Transaction{tx})
+	assertRejected(t, bc, err, ErrWrongChain, 1, map[string]amount.Amount{
+		user.address:      amount.MustParse("100"),
+		validator.address: amount.MustParse("100"),
+	})
+}
+
+func TestCommitBlockRejectsWrongChainStake(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	tx := signedTransaction(t, "avaf-othernet", TxTypeStake, user.address, pos.StakingAddress, user.key, 0)
+
+	err := commitTestBlock(t, bc, validator, []Transaction{tx})
+	assertRejected(t, bc, err, ErrWrongChain, 1, map[string]amount.Amount{
+		user.address: amount.MustParse("100"),
+	})
+	if stake, _ := bc.StakingWallet.GetStake(user.address); !stake.IsZero() {
+		t.Fatalf("stake of %s = %s, want 0", user.address, stake)
+	}
+}
+
+func TestSubmitTransactionRejectsWrongChain(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	for _, tx := range []Transaction{
+		signedTransfer(t, "avaf-othernet", user.address, validator.address, user.key, 0),
+		signedTransaction(t, "avaf-othernet", TxTypeStake, user.address, pos.StakingAddress, user.key, 0),
+	} {
+		if err := bc.SubmitTransaction(tx); !errors.Is(err, ErrWrongChain) {
+			t.Fatalf("SubmitTransaction(%s) error = %v, want %v", tx.Type, err, ErrWrongChain)
+		}
+	}
+	if n := bc.Mempool.Len(); n != 0 {
+		t.Fatalf("mempool holds %d transactions, want 0", n)
+	}
+
+	// Та же транзакция для своей сети принимается
+	tx := signedTransaction(t, bc.Genesis().ChainID, TxTypeStake, user.address, pos.StakingAddress, user.key, 0)
+	if err := bc.SubmitTransaction(tx); err != nil {
+		t.Fatalf("SubmitTransaction: %v", err)
+	}
+}
+
+func TestCommitBlockCreditsUnknownRecipient(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	// У получателя нет записи аккаунта на этом узле
+	recipient := newTestAccount(t)
+	tx := signedTransfer(t, bc.Genesis().ChainID, user.address, recipient.address, user.key, 0)
+	if err := commitTestBlock(t, bc, validator, []Transaction{tx}); err != nil {
+		t.Fatalf("CommitBlock: %v", err)
+	}
+
+	wallet, err := bc.AccountManager.LoadAccount(recipient.address)
+	if err != nil {
+		t.Fatalf("LoadAccount: %v", err)
+	}
+	if got := wallet.Balance[FeeCurrency]; got != amount.MustParse("1") {
+		t.Fatalf("recipient balance = %s, want 1", got)
+	}
+	if wallet.Nonce != 0 || wallet.PublicKey != "" {
+		t.Fatalf("recipient record = %+v, want only address and balance", wallet)
+	}
+
+	// Следующий перевод зачисляется на ту же запись
+	tx = signedTransfer(t, bc.Genesis().ChainID, user.address, recipient.address, user.key, 1)
+	if err := commitTestBlock(t, bc, validator, []Transaction{tx}); err != nil {
+		t.Fatalf("CommitBlock: %v", err)
+	}
+	if balance, _ := bc.AccountManager.GetBalance(recipient.address); balance[FeeCurrency] != amount.MustParse("2") {
+		t.Fatalf("recipient balance = %s, want 2", balance[FeeCurrency])
+	}
+}
+
+func TestIndexBlocksRunsOnce(t *testing.T) {
+	db := adb.NewMemoryDB()
+	for key, value := range map[string]string{
+		"block_0": legacyGenesisJSON,
+		"block_1": legacyBlockJSON,
+	} {
+		if err := db.Save(key, []byte(value)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// Блоки сохранены до появления индексов: первый запуск их дописывает
+	if _, err := NewBlockchainWithGenesis(db, DefaultGenesis()); err != nil {
+		t.Fatalf("NewBlockchainWithGenesis: %v", err)
+	}
+	txKey := transactionKey("735aa3b4e0f0d763244a05c2c1365f3cb2ec7f4699261d905f21dd431d513517")
+	if _, err := db.Load(txKey); err != nil {
+		t.Fatalf("transaction index was not written: %v", err)
+	}
+	if _, err := db.Load(indexVersionKey); err != nil {
+		t.Fatalf("index version was not written: %v", err)
+	}
+
+	// Следующие запуски индексы не перечитывают
+	if err := db.Delete(txKey); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := NewBlockchainWithGenesis(db, DefaultGenesis()); err != nil {
+		t.Fatalf("NewBlockchainWithGenesis: %v", err)
+	}
+	if _, err := db.Load(txKey); !errors.Is(err, adb.ErrNotFound) {
+		t.Fatalf("indexes were rebuilt on a second start: %v", err)
+	}
+}
+
+func TestNewChainStoresIndexVersion(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+	if _, err := bc.db.Load(indexVersionKey); err != nil {
+		t.Fatalf("index version was not written with the genesis block: %v", err)
+	}
+}
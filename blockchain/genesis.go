@@ -0,0 +1,246 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	AA "github.com/HHpCpp/AVAF/accounts"
+	avafdb "github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+// DefaultChainID — идентификатор сети разработки, используемый без файла генезиса
+const DefaultChainID = "avaf-devnet"
+
+// ErrInvalidGenesis возвращается для некорректной спецификации генезиса
+var ErrInvalidGenesis = errors.New("invalid genesis specification")
+
+// GenesisAlloc — начальный баланс аккаунта. Публичный ключ нужен, чтобы
+// владелец мог подписывать транзакции, не создавая аккаунт заново.
+type GenesisAlloc struct {
+	Address   string        `json:"address"`
+	PublicKey string        `json:"publicKey"` // hex(X || Y)
+	Balance   amount.Amount `json:"balance"`
+}
+
+// GenesisStake — начальный стейк валидатора
+type GenesisStake struct {
+	Address string        `json:"address"`
+	Stake   amount.Amount `json:"stake"`
+}
+
+// FeeParams — параметры комиссии сети
+type FeeParams struct {
+	Afuel         uint64        `json:"afuel"`         // Afuel, который должен оплатить перевод
+	MinAfuelPrice amount.Amount `json:"minAfuelPrice"` // Минимальная цена Afuel
+}
+
+// GenesisSpec описывает начальное состояние сети. Это единственный источник
+// средств: аккаунты, созданные позже, начинают с нулевым балансом.
+type GenesisSpec struct {
+	ChainID     string         `json:"chainId"`
+	Timestamp   string         `json:"timestamp"`   // Время генезиса в RFC 3339, начало слота 0
+	SlotSeconds uint64         `json:"slotSeconds"` // Длительность слота; от нее зависят номера слотов и выбор валидатора
+	Alloc       []GenesisAlloc `json:"alloc"`
+	Validators  []GenesisStake `json:"validators"`
+	Fees        FeeParams      `json:"fees"`
+}
+
+// DefaultGenesis возвращает спецификацию сети разработки без начальных средств
+func DefaultGenesis() GenesisSpec {
+	return GenesisSpec{
+		ChainID:     DefaultChainID,
+		Timestamp:   GenesisTimestamp,
+		SlotSeconds: uint64(DefaultSlotTime / time.Second),
+		Fees: FeeParams{
+			Afuel:         DefaultAfuel,
+			MinAfuelPrice: DefaultAfuelPrice,
+		},
+	}
+}
+
+// maxSlotSeconds ограничивает длительность слота сутками
+const maxSlotSeconds = 24 * 60 * 60
+
+// SlotTime возвращает длительность слота сети
+func (g GenesisSpec) SlotTime() time.Duration {
+	return time.Duration(g.SlotSeconds) * time.Second
+}
+
+// LoadGenesis читает и проверяет спецификацию генезиса из JSON-файла
+func LoadGenesis(path string) (GenesisSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GenesisSpec{}, fmt.Errorf("failed to read genesis file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var spec GenesisSpec
+	if err := decoder.Decode(&spec); err != nil {
+		return GenesisSpec{}, fmt.Errorf("%w: %v", ErrInvalidGenesis, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return GenesisSpec{}, err
+	}
+	return spec, nil
+}
+
+// Validate проверяет спецификацию: формат времени, длительность слота, уникальность адресов,
+// соответствие адресов публичным ключам и наличие аккаунтов у валидаторов
+func (g GenesisSpec) Validate() error {
+	if g.ChainID == "" {
+		return fmt.Errorf("%w: chainId is required", ErrInvalidGenesis)
+	}
+	if _, err := time.Parse(time.RFC3339, g.Timestamp); err != nil {
+		return fmt.Errorf("%w: timestamp: %v", ErrInvalidGenesis, err)
+	}
+	if g.SlotSeconds == 0 || g.SlotSeconds > maxSlotSeconds {
+		return fmt.Errorf("%w: slotSeconds must be between 1 and %d", ErrInvalidGenesis, maxSlotSeconds)
+	}
+	if g.Fees.Afuel == 0 {
+		return fmt.Errorf("%w: fees.afuel must be greater than 0", ErrInvalidGenesis)
+	}
+
+	allocated := make(map[string]bool, len(g.Alloc))
+	for _, alloc := range g.Alloc {
+		if allocated[alloc.Address] {
+			return fmt.Errorf("%w: duplicate allocation for %s", ErrInvalidGenesis, alloc.Address)
+		}
+		allocated[alloc.Address] = true
+
+		publicKey, err := decodePublicKey(alloc.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: allocation %s: %v", ErrInvalidGenesis, alloc.Address, err)
+		}
+		if address := crypto.PubkeyToAddress(*publicKey); address != alloc.Address {
+			return fmt.Errorf("%w: allocation %s: public key belongs to %s", ErrInvalidGenesis, alloc.Address, address)
+		}
+	}
+
+	staked := make(map[string]bool, len(g.Validators))
+	for _, validator := range g.Validators {
+		if staked[validator.Address] {
+			return fmt.Errorf("%w: duplicate stake for %s", ErrInvalidGenesis, validator.Address)
+		}
+		staked[validator.Address] = true
+
+		if !allocated[validator.Address] {
+			return fmt.Errorf("%w: validator %s has no allocation", ErrInvalidGenesis, validator.Address)
+		}
+		if validator.Stake.IsZero() {
+			return fmt.Errorf("%w: validator %s has zero stake", ErrInvalidGenesis, validator.Address)
+		}
+	}
+	return nil
+}
+
+// Hash возвращает хеш спецификации. Распределения и стейки сортируются
+// по адресу, поэтому порядок записей в файле на хеш не влияет.
+func (g GenesisSpec) Hash() [32]byte {
+	canonical := g
+	canonical.Alloc = append([]GenesisAlloc(nil), g.Alloc...)
+	sort.Slice(canonical.Alloc, func(i, j int) bool {
+		return canonical.Alloc[i].Address < canonical.Alloc[j].Address
+	})
+	canonical.Validators = append([]GenesisStake(nil), g.Validators...)
+	sort.Slice(canonical.Validators, func(i, j int) bool {
+		return canonical.Validators[i].Address < canonical.Validators[j].Address
+	})
+
+	// Поля структуры кодируются в фиксированном порядке, суммы — строками
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		panic(fmt.Sprintf("failed to marshal genesis: %v", err))
+	}
+	return sha256.Sum256(data)
+}
+
+// Block возвращает генезис-блок спецификации. У генезиса нет родителя,
+// поэтому PrevHash хранит хеш спецификации, и хеш блока фиксирует
+// все начальные балансы, стейки и параметры сети.
+func (g GenesisSpec) Block() Block {
+	specHash := g.Hash()
+	block := Block{
+		Version:      CurrentBlockVersion,
+		Index:        0,
+		Timestamp:    g.Timestamp,
+		Transactions: []Transaction{},
+		PrevHash:     hex.EncodeToString(specHash[:]),
+	}
+	block.MerkleRoot = block.CalculateMerkleRoot()
+	block.Hash = block.CalculateHash()
+	return block
+}
+
+// stageGenesis добавляет в пакет генезис-блок, начальные аккаунты и стейки
+func (bc *Blockchain) stageGenesis(batch *avafdb.Batch) error {
+	for _, alloc := range bc.genesis.Alloc {
+		wallet := AA.Wallet{
+			Address:   alloc.Address,
+			Balance:   map[string]amount.Amount{FeeCurrency: alloc.Balance},
+			PublicKey: alloc.PublicKey,
+		}
+		if err := bc.AccountManager.PutAccount(batch, wallet); err != nil {
+			return err
+		}
+	}
+	validators := make(map[string]amount.Amount, len(bc.genesis.Validators))
+	for _, validator := range bc.genesis.Validators {
+		bc.StakingWallet.PutStake(batch, validator.Address, validator.Stake)
+		validators[validator.Address] = validator.Stake
+	}
+	if err := bc.StakingWallet.PutValidatorSet(batch, 0, validators); err != nil {
+		return err
+	}
+	// Новая база сразу получает все индексы, дописывать их не нужно
+	putIndexVersion(batch)
+	return putBlock(batch, bc.genesis.Block())
+}
+
+// isLegacyGenesis сообщает, что блок — генезис, созданный до появления
+// спецификации генезиса: блок старого формата без родителя и транзакций,
+// время которого бралось из часов узла
+func isLegacyGenesis(block Block) bool {
+	return block.Index == 0 &&
+		block.Version == BlockVersionLegacy &&
+		block.PrevHash == "" &&
+		len(block.Transactions) == 0 &&
+		block.Proposer == ""
+}
+
+// checkGenesis проверяет, что сохраненный генезис-блок создан из спецификации
+// цепочки. Генезис старого формата воспроизвести нельзя, поэтому он
+// принимается как есть, если его хеш верен, а спецификация не задает
+// начального состояния, которого в такой базе не может быть.
+func (bc *Blockchain) checkGenesis(block Block) error {
+	genesisBlock := bc.genesis.Block()
+	if block.Hash == genesisBlock.Hash {
+		return nil
+	}
+	if !isLegacyGenesis(block) {
+		return fmt.Errorf("%w: stored %s, configured %s", ErrGenesisMismatch, block.Hash, genesisBlock.Hash)
+	}
+	if len(bc.genesis.Alloc) > 0 || len(bc.genesis.Validators) > 0 {
+		return fmt.Errorf("%w: legacy genesis %s cannot carry the configured allocations and validators",
+			ErrGenesisMismatch, block.Hash)
+	}
+	if err := block.checkHash(); err != nil {
+		return fmt.Errorf("%w: %v", ErrGenesisMismatch, err)
+	}
+	return nil
+}
+
+// Genesis возвращает спецификацию генезиса цепочки
+func (bc *Blockchain) Genesis() GenesisSpec {
+	return bc.genesis
+}
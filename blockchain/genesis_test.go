@@ -0,0 +1,175 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+// testGenesis возвращает корректную спецификацию с двумя аккаунтами,
+// первый из которых — валидатор
+func testGenesis(t *testing.T) (GenesisSpec, testAccount, testAccount) {
+	t.Helper()
+
+	validator, user := newTestAccount(t), newTestAccount(t)
+	genesis := DefaultGenesis()
+	genesis.ChainID = "avaf-testnet"
+	for _, account := range []testAccount{validator, user} {
+		genesis.Alloc = append(genesis.Alloc, GenesisAlloc{
+			Address:   account.address,
+			PublicKey: hex.EncodeToString(crypto.MarshalPublicKey(&account.key.PublicKey)),
+			Balance:   amount.MustParse("100"),
+		})
+	}
+	genesis.Validators = []GenesisStake{{Address: validator.address, Stake: amount.MustParse("10")}}
+	return genesis, validator, user
+}
+
+func TestLoadGenesis(t *testing.T) {
+	genesis, _, _ := testGenesis(t)
+	data, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	loaded, err := LoadGenesis(path)
+	if err != nil {
+		t.Fatalf("LoadGenesis: %v", err)
+	}
+	if loaded.Hash() != genesis.Hash() {
+		t.Fatal("loaded genesis differs from the written one")
+	}
+
+	// Опечатка в имени поля не должна молча давать другую сеть
+	typo := strings.Replace(string(data), `"validators"`, `"validator"`, 1)
+	if err := os.WriteFile(path, []byte(typo), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := LoadGenesis(path); !errors.Is(err, ErrInvalidGenesis) {
+		t.Fatalf("LoadGenesis with an unknown field error = %v, want %v", err, ErrInvalidGenesis)
+	}
+
+	if _, err := LoadGenesis(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("LoadGenesis of a missing file succeeded")
+	}
+}
+
+func TestGenesisValidate(t *testing.T) {
+	genesis, validator, user := testGenesis(t)
+	if err := genesis.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	for name, change := range map[string]func(g *GenesisSpec){
+		"no chain id":     func(g *GenesisSpec) { g.ChainID = "" },
+		"bad timestamp":   func(g *GenesisSpec) { g.Timestamp = "yesterday" },
+		"zero afuel":      func(g *GenesisSpec) { g.Fees.Afuel = 0 },
+		"duplicate alloc": func(g *GenesisSpec) { g.Alloc = append(g.Alloc, g.Alloc[0]) },
+		"foreign key": func(g *GenesisSpec) {
+			g.Alloc[1].PublicKey = g.Alloc[0].PublicKey
+		},
+		"bad key": func(g *GenesisSpec) { g.Alloc[1].PublicKey = "00" },
+		"validator without alloc": func(g *GenesisSpec) {
+			g.Validators = append(g.Validators, GenesisStake{Address: newTestAccount(t).address, Stake: amount.MustParse("1")})
+		},
+		"duplicate stake": func(g *GenesisSpec) { g.Validators = append(g.Validators, g.Validators[0]) },
+		"zero stake": func(g *GenesisSpec) {
+			g.Validators = []GenesisStake{{Address: user.address}}
+		},
+	} {
+		invalid := genesis
+		invalid.Alloc = append([]GenesisAlloc(nil), genesis.Alloc...)
+		invalid.Validators = append([]GenesisStake(nil), genesis.Validators...)
+		change(&invalid)
+		if err := invalid.Validate(); !errors.Is(err, ErrInvalidGenesis) {
+			t.Fatalf("%s: Validate error = %v, want %v", name, err, ErrInvalidGenesis)
+		}
+	}
+
+	// Порядок записей в файле на хеш не влияет
+	reordered := genesis
+	reordered.Alloc = []GenesisAlloc{genesis.Alloc[1], genesis.Alloc[0]}
+	if reordered.Hash() != genesis.Hash() {
+		t.Fatal("genesis hash depends on allocation order")
+	}
+	changed := genesis
+	changed.Validators = []GenesisStake{{Address: validator.address, Stake: amount.MustParse("11")}}
+	if changed.Hash() == genesis.Hash() {
+		t.Fatal("genesis hash does not depend on stakes")
+	}
+}
+
+func TestGenesisInitialState(t *testing.T) {
+	genesis, validator, user := testGenesis(t)
+	db := adb.NewMemoryDB()
+	bc, err := NewBlockchainWithGenesis(db, genesis)
+	if err != nil {
+		t.Fatalf("NewBlockchainWithGenesis: %v", err)
+	}
+
+	for _, address := range []string{validator.address, user.address} {
+		if balance, _ := bc.AccountManager.GetBalance(address); balance[FeeCurrency] != amount.MustParse("100") {
+			t.Fatalf("balance of %s = %s, want 100", address, balance[FeeCurrency])
+		}
+	}
+	if stake, _ := bc.StakingWallet.GetStake(validator.address); stake != amount.MustParse("10") {
+		t.Fatalf("stake of %s = %s, want 10", validator.address, stake)
+	}
+	if got, want := bc.Chain[0].Hash, genesis.Block().Hash; got != want {
+		t.Fatalf("genesis block hash = %s, want %s", got, want)
+	}
+
+	// Та же база с другой спецификацией не открывается
+	other := genesis
+	other.ChainID = "avaf-othernet"
+	if _, err := NewBlockchainWithGenesis(db, other); !errors.Is(err, ErrGenesisMismatch) {
+		t.Fatalf("NewBlockchainWithGenesis with another genesis error = %v, want %v", err, ErrGenesisMismatch)
+	}
+	if _, err := NewBlockchainWithGenesis(db, genesis); err != nil {
+		t.Fatalf("reopening with the same genesis: %v", err)
+	}
+}
+
+func TestGenesisSlotTime(t *testing.T) {
+	genesis := DefaultGenesis()
+	if got := genesis.SlotTime(); got != DefaultSlotTime {
+		t.Fatalf("SlotTime = %v, want %v", got, DefaultSlotTime)
+	}
+
+	// Длительность слота — параметр сети: другая длительность дает другой генезис
+	slower := genesis
+	slower.SlotSeconds = 10
+	if slower.Hash() == genesis.Hash() {
+		t.Fatal("genesis hash does not depend on slotSeconds")
+	}
+
+	for _, slotSeconds := range []uint64{0, maxSlotSeconds + 1} {
+		invalid := genesis
+		invalid.SlotSeconds = slotSeconds
+		if err := invalid.Validate(); !errors.Is(err, ErrInvalidGenesis) {
+			t.Fatalf("Validate(slotSeconds=%d) error = %v, want %v", slotSeconds, err, ErrInvalidGenesis)
+		}
+	}
+
+	bc, err := NewBlockchainWithGenesis(adb.NewMemoryDB(), slower)
+	if err != nil {
+		t.Fatalf("NewBlockchainWithGenesis: %v", err)
+	}
+	start, _ := time.Parse(time.RFC3339, slower.Timestamp)
+	if got := bc.SlotAt(start.Add(25 * time.Second)); got != 2 {
+		t.Fatalf("SlotAt(+25s) = %d, want 2", got)
+	}
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultHistoryLimit — размер страницы истории, если limit не задан
+	DefaultHistoryLimit = 50
+	// MaxHistoryLimit ограничивает размер одной страницы истории
+	MaxHistoryLimit = 1000
+)
+
+// addressHistoryPrefix возвращает префикс ключей истории транзакций адреса
+func addressHistoryPrefix(address string) string {
+	return "addrtx_" + address + "_"
+}
+
+// addressHistoryCursor кодирует позицию транзакции так, чтобы
+// лексикографический порядок ключей совпадал с порядком в цепочке
+func addressHistoryCursor(blockIndex, position int) string {
+	return fmt.Sprintf("%010d_%05d", blockIndex, position)
+}
+
+// addressHistoryKey возвращает ключ индекса адрес -> транзакция
+func addressHistoryKey(address string, blockIndex, position int) string {
+	return addressHistoryPrefix(address) + addressHistoryCursor(blockIndex, position)
+}
+
+// GetTransactionsByAddress возвращает страницу транзакций, в которых адрес
+// был отправителем или получателем, в порядке включения в цепочку.
+// cursor — значение, возвращенное предыдущим вызовом (пустая строка — с начала).
+// Пустой следующий курсор означает, что история закончилась.
+func (bc *Blockchain) GetTransactionsByAddress(address string, cursor string, limit int) ([]TransactionRecord, string, error) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	if limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+
+	prefix := addressHistoryPrefix(address)
+
+	iter := bc.db.NewPrefixIterator(prefix)
+	defer iter.Release()
+
+	// Переходим к позиции курсора; сам курсор уже был возвращен ранее
+	start := prefix + cursor
+	var records []TransactionRecord
+	nextCursor := ""
+	for ok := iter.Seek([]byte(start)); ok; ok = iter.Next() {
+		key := string(iter.Key())
+		if cursor != "" && key == start {
+			continue
+		}
+
+		if len(records) == limit {
+			// Есть еще записи — отдаем курсор на последнюю возвращенную
+			last := records[len(records)-1]
+			nextCursor = addressHistoryCursor(last.BlockIndex, last.Position)
+			break
+		}
+
+		record, err := LoadTransactionRecord(bc.db, string(iter.Value()))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load transaction for %s: %w", key, err)
+		}
+		records = append(records, record)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, "", fmt.Errorf("iterator error: %w", err)
+	}
+
+	return records, nextCursor, nil
+}
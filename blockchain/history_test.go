@@ -0,0 +1,58 @@
+package blockchain
+
+import "testing"
+
+func TestGetTransactionsByAddressPaging(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+	chainID := bc.Genesis().ChainID
+
+	// Пять переводов в двух блоках
+	var want []string
+	for _, nonces := range [][]uint64{{0, 1, 2}, {3, 4}} {
+		var transactions []Transaction
+		for _, nonce := range nonces {
+			tx := signedTransfer(t, chainID, user.address, validator.address, user.key, nonce)
+			transactions = append(transactions, tx)
+			want = append(want, tx.Hash)
+		}
+		if err := commitTestBlock(t, bc, validator, transactions); err != nil {
+			t.Fatalf("CommitBlock: %v", err)
+		}
+	}
+
+	// История одинакова у отправителя и получателя
+	for _, address := range []string{user.address, validator.address} {
+		var got []string
+		var pages int
+		cursor := ""
+		for {
+			records, next, err := bc.GetTransactionsByAddress(address, cursor, 2)
+			if err != nil {
+				t.Fatalf("GetTransactionsByAddress: %v", err)
+			}
+			for _, record := range records {
+				got = append(got, record.Transaction.Hash)
+			}
+			pages++
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if pages != 3 || !equalStrings(got, want) {
+			t.Fatalf("history of %s = %v in %d pages, want %v in 3 pages", address, got, pages, want)
+		}
+	}
+
+	// Страница ровно до конца истории не возвращает курсор
+	records, next, err := bc.GetTransactionsByAddress(user.address, "", len(want))
+	if err != nil || len(records) != len(want) || next != "" {
+		t.Fatalf("full page = %d records, cursor %q, %v; want %d records and no cursor", len(records), next, err, len(want))
+	}
+
+	records, next, err = bc.GetTransactionsByAddress(newTestAccount(t).address, "", 0)
+	if err != nil || len(records) != 0 || next != "" {
+		t.Fatalf("history of an unknown address = %d records, cursor %q, %v", len(records), next, err)
+	}
+}
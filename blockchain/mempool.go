@@ -0,0 +1,363 @@
+package blockchain
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MempoolConfig задает лимиты пула ожидающих транзакций
+type MempoolConfig struct {
+	MaxSize int           // Максимальное число транзакций в пуле
+	MaxAge  time.Duration // Время, после которого транзакция удаляется из пула
+}
+
+// DefaultMempoolConfig возвращает лимиты пула по умолчанию
+func DefaultMempoolConfig() MempoolConfig {
+	return MempoolConfig{
+		MaxSize: 5000,
+		MaxAge:  time.Hour,
+	}
+}
+
+var (
+	ErrKnownTransaction = errors.New("transaction already in mempool")
+	ErrNonceConflict    = errors.New("another pending transaction uses this nonce")
+	ErrMempoolFull      = errors.New("mempool is full")
+)
+
+// pendingTx — транзакция в пуле и время ее поступления
+type pendingTx struct {
+	tx      Transaction
+	addedAt time.Time
+}
+
+// Mempool хранит проверенные, но еще не включенные в блок транзакции.
+// Транзакции одного отправителя всегда идут подряд по nonce.
+type Mempool struct {
+	mu       sync.RWMutex
+	config   MempoolConfig
+	txs      map[string]*pendingTx            // Хеш -> транзакция
+	bySender map[string]map[uint64]*pendingTx // Отправитель -> nonce -> транзакция
+	now      func() time.Time
+}
+
+// NewMempool создает пустой пул с заданными лимитами
+func NewMempool(config MempoolConfig) *Mempool {
+	return &Mempool{
+		config:   config,
+		txs:      make(map[string]*pendingTx),
+		bySender: make(map[string]map[uint64]*pendingTx),
+		now:      time.Now,
+	}
+}
+
+// Add добавляет транзакцию в пул. Вызывающий код отвечает за проверку
+// подписи, баланса и того, что nonce продолжает очередь отправителя.
+// При переполнении вытесняется транзакция другого отправителя с наименьшей
+// AfuelPrice, если новая платит больше; иначе возвращается ErrMempoolFull.
+func (mp *Mempool) Add(tx Transaction) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	now := mp.now()
+	mp.pruneLocked(now)
+
+	if _, ok := mp.txs[tx.Hash]; ok {
+		return fmt.Errorf("%w: %s", ErrKnownTransaction, tx.Hash)
+	}
+	if _, ok := mp.bySender[tx.Sender][tx.Nonce]; ok {
+		return fmt.Errorf("%w: %s nonce %d", ErrNonceConflict, tx.Sender, tx.Nonce)
+	}
+
+	if mp.config.MaxSize > 0 && len(mp.txs) >= mp.config.MaxSize {
+		victim := mp.evictionCandidateLocked(tx.Sender)
+		if victim == nil || victim.tx.AfuelPrice >= tx.AfuelPrice {
+			return ErrMempoolFull
+		}
+		mp.removeLocked(victim.tx, true)
+	}
+
+	ptx := &pendingTx{tx: tx, addedAt: now}
+	mp.txs[tx.Hash] = ptx
+	if mp.bySender[tx.Sender] == nil {
+		mp.bySender[tx.Sender] = make(map[uint64]*pendingTx)
+	}
+	mp.bySender[tx.Sender][tx.Nonce] = ptx
+	return nil
+}
+
+// Get возвращает ожидающую транзакцию по хешу
+func (mp *Mempool) Get(hash string) (Transaction, bool) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	ptx, ok := mp.txs[hash]
+	if !ok {
+		return Transaction{}, false
+	}
+	return ptx.tx, true
+}
+
+// Len возвращает количество транзакций в пуле
+func (mp *Mempool) Len() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return len(mp.txs)
+}
+
+// Pending возвращает все ожидающие транзакции в порядке включения в блок
+func (mp *Mempool) Pending() []Transaction {
+	return mp.Select(0)
+}
+
+// PendingBySender возвращает ожидающие транзакции отправителя по возрастанию nonce
+func (mp *Mempool) PendingBySender(sender string) []Transaction {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.senderQueueLocked(sender)
+}
+
+// NextNonce возвращает nonce для новой транзакции отправителя с учетом
+// его ожидающих транзакций; accountNonce — nonce из зафиксированного состояния
+func (mp *Mempool) NextNonce(sender string, accountNonce uint64) uint64 {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	nonce := accountNonce
+	for {
+		if _, ok := mp.bySender[sender][nonce]; !ok {
+			return nonce
+		}
+		nonce++
+	}
+}
+
+// Select возвращает до limit транзакций (все при limit <= 0) для сборки блока:
+// сначала с большей AfuelPrice, но у каждого отправителя строго по возрастанию nonce
+func (mp *Mempool) Select(limit int) []Transaction {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	queues := make(map[string][]Transaction, len(mp.bySender))
+	heads := &priceHeap{}
+	for sender := range mp.bySender {
+		queue := mp.senderQueueLocked(sender)
+		if len(queue) == 0 {
+			continue
+		}
+		queues[sender] = queue[1:]
+		heap.Push(heads, mp.txs[queue[0].Hash])
+	}
+
+	var selected []Transaction
+	for heads.Len() > 0 && (limit <= 0 || len(selected) < limit) {
+		next := heap.Pop(heads).(*pendingTx)
+		selected = append(selected, next.tx)
+
+		sender := next.tx.Sender
+		if queue := queues[sender]; len(queue) > 0 {
+			queues[sender] = queue[1:]
+			heap.Push(heads, mp.txs[queue[0].Hash])
+		}
+	}
+	return selected
+}
+
+// Remove удаляет транзакции из пула вместе с зависящими от них
+// транзакциями того же отправителя с большими nonce
+func (mp *Mempool) Remove(hashes ...string) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, hash := range hashes {
+		if ptx, ok := mp.txs[hash]; ok {
+			mp.removeLocked(ptx.tx, true)
+		}
+	}
+}
+
+// RemoveIncluded удаляет из пула транзакции, вошедшие в блок, а также
+// ожидающие транзакции тех же отправителей с уже использованными nonce
+func (mp *Mempool) RemoveIncluded(transactions []Transaction) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, tx := range transactions {
+		if ptx, ok := mp.txs[tx.Hash]; ok {
+			mp.removeLocked(ptx.tx, false)
+		}
+		if ptx, ok := mp.bySender[tx.Sender][tx.Nonce]; ok {
+			mp.removeLocked(ptx.tx, false)
+		}
+	}
+}
+
+// Prune удаляет транзакции старше MaxAge
+func (mp *Mempool) Prune() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.pruneLocked(mp.now())
+}
+
+func (mp *Mempool) pruneLocked(now time.Time) {
+	if mp.config.MaxAge <= 0 {
+		return
+	}
+	for _, ptx := range mp.txs {
+		if now.Sub(ptx.addedAt) > mp.config.MaxAge {
+			mp.removeLocked(ptx.tx, true)
+		}
+	}
+}
+
+// removeLocked удаляет транзакцию; при cascade удаляются и последующие
+// nonce отправителя, которые без нее уже не могут быть включены
+func (mp *Mempool) removeLocked(tx Transaction, cascade bool) {
+	delete(mp.txs, tx.Hash)
+	queue := mp.bySender[tx.Sender]
+	delete(queue, tx.Nonce)
+
+	if cascade {
+		for nonce, ptx := range queue {
+			if nonce > tx.Nonce {
+				delete(mp.txs, ptx.tx.Hash)
+				delete(queue, nonce)
+			}
+		}
+	}
+	if len(queue) == 0 {
+		delete(mp.bySender, tx.Sender)
+	}
+}
+
+// evictionCandidateLocked выбирает транзакцию для вытеснения: самую дешевую
+// среди последних в очереди каждого отправителя, чтобы не создавать разрывов nonce.
+// Очередь incoming пропускается: новая транзакция продолжает ее, и вытеснение
+// последней транзакции этой очереди оставило бы разрыв перед новой.
+func (mp *Mempool) evictionCandidateLocked(incoming string) *pendingTx {
+	var victim *pendingTx
+	for sender := range mp.bySender {
+		if sender == incoming {
+			continue
+		}
+		queue := mp.senderQueueLocked(sender)
+		if len(queue) == 0 {
+			continue
+		}
+		last := mp.txs[queue[len(queue)-1].Hash]
+		if victim == nil || last.tx.AfuelPrice < victim.tx.AfuelPrice ||
+			(last.tx.AfuelPrice == victim.tx.AfuelPrice && last.addedAt.After(victim.addedAt)) {
+			victim = last
+		}
+	}
+	return victim
+}
+
+// senderQueueLocked возвращает транзакции отправителя по возрастанию nonce
+func (mp *Mempool) senderQueueLocked(sender string) []Transaction {
+	queue := make([]Transaction, 0, len(mp.bySender[sender]))
+	for _, ptx := range mp.bySender[sender] {
+		queue = append(queue, ptx.tx)
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		return queue[i].Nonce < queue[j].Nonce
+	})
+	return queue
+}
+
+// priceHeap упорядочивает транзакции по убыванию AfuelPrice,
+// при равной цене — по времени поступления, затем по хешу
+type priceHeap []*pendingTx
+
+func (h priceHeap) Len() int { return len(h) }
+
+func (h priceHeap) Less(i, j int) bool {
+	if h[i].tx.AfuelPrice != h[j].tx.AfuelPrice {
+		return h[i].tx.AfuelPrice > h[j].tx.AfuelPrice
+	}
+	if !h[i].addedAt.Equal(h[j].addedAt) {
+		return h[i].addedAt.Before(h[j].addedAt)
+	}
+	return h[i].tx.Hash < h[j].tx.Hash
+}
+
+func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *priceHeap) Push(x any) { *h = append(*h, x.(*pendingTx)) }
+
+func (h *priceHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// MaxBlockTransactions ограничивает число транзакций, собираемых из пула в один блок
+const MaxBlockTransactions = 500
+
+// ErrNoPendingTransactions возвращается, если в пуле нет применимых транзакций
+var ErrNoPendingTransactions = errors.New("no pending transactions")
+
+// SubmitTransaction проверяет транзакцию и добавляет ее в пул. Nonce должен
+// продолжать очередь отправителя, а баланса должно хватать на все его
+// ожидающие транзакции вместе с новой.
+func (bc *Blockchain) SubmitTransaction(tx Transaction) error {
+	if err := bc.verifyTransaction(tx); err != nil {
+		return err
+	}
+
+	current, err := bc.GetCurrentBlock()
+	if err != nil {
+		return err
+	}
+
+	// Проигрываем очередь отправителя поверх текущего состояния
+	state := bc.newStateDB(current.Index)
+	for _, pending := range bc.Mempool.PendingBySender(tx.Sender) {
+		if pending.Hash == tx.Hash {
+			return fmt.Errorf("%w: %s", ErrKnownTransaction, tx.Hash)
+		}
+		if err := state.applyTransaction(pending); err != nil {
+			return fmt.Errorf("pending transaction %s is no longer valid: %w", pending.Hash, err)
+		}
+	}
+	if err := state.applyTransaction(tx); err != nil {
+		return err
+	}
+
+	return bc.Mempool.Add(tx)
+}
+
+// selectApplicable отбирает из пула до limit транзакций, которые можно применить
+// к текущему состоянию подряд; неприменимые транзакции удаляются из пула
+func (bc *Blockchain) selectApplicable(parent Block, limit int) []Transaction {
+	state := bc.newStateDB(parent.Index)
+
+	var rejected []string
+	var transactions []Transaction
+	for _, tx := range bc.Mempool.Select(limit) {
+		if err := bc.checkNotCommitted(tx.Hash); err != nil {
+			rejected = append(rejected, tx.Hash)
+			continue
+		}
+		if err := state.applyTransaction(tx); err != nil {
+			rejected = append(rejected, tx.Hash)
+			continue
+		}
+		transactions = append(transactions, tx)
+	}
+
+	bc.Mempool.Remove(rejected...)
+	return transactions
+}
+
+// CommitPending собирает блок из ожидающих транзакций пула и добавляет его
+// в цепочку, если этот узел — валидатор текущего слота
+func (bc *Blockchain) CommitPending() (*Block, error) {
+	return NewProducer(bc, DefaultProducerConfig()).ProduceBlock()
+}
@@ -0,0 +1,154 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/HHpCpp/AVAF/amount"
+)
+
+// pendingTransaction создает транзакцию для пула; пул не проверяет подписи,
+// поэтому достаточно хеша, отправителя, nonce и цены
+func pendingTransaction(sender string, nonce uint64, price string) Transaction {
+	return Transaction{
+		Hash:       fmt.Sprintf("%s/%d/%s", sender, nonce, price),
+		Sender:     sender,
+		Nonce:      nonce,
+		AfuelPrice: amount.MustParse(price),
+	}
+}
+
+// testMempool создает пул с часами, которые двигает сам тест
+func testMempool(config MempoolConfig) (*Mempool, *time.Time) {
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	mp := NewMempool(config)
+	mp.now = func() time.Time { return now }
+	return mp, &now
+}
+
+func addPending(t *testing.T, mp *Mempool, transactions ...Transaction) {
+	t.Helper()
+	for _, tx := range transactions {
+		if err := mp.Add(tx); err != nil {
+			t.Fatalf("Add(%s): %v", tx.Hash, err)
+		}
+	}
+}
+
+func hashes(transactions []Transaction) []string {
+	result := make([]string, len(transactions))
+	for i, tx := range transactions {
+		result[i] = tx.Hash
+	}
+	return result
+}
+
+func TestMempoolSelectOrder(t *testing.T) {
+	mp, _ := testMempool(DefaultMempoolConfig())
+	addPending(t, mp,
+		pendingTransaction("a", 0, "0.0001"),
+		pendingTransaction("a", 1, "0.01"),
+		pendingTransaction("b", 0, "0.001"),
+		pendingTransaction("c", 0, "0.0001"),
+	)
+
+	// Дорогая a/1 ждет свою a/0, у равных цен раньше идет поступившая раньше
+	want := []string{"b/0/0.001", "a/0/0.0001", "a/1/0.01", "c/0/0.0001"}
+	if got := hashes(mp.Pending()); !equalStrings(got, want) {
+		t.Fatalf("Pending = %v, want %v", got, want)
+	}
+	if got := hashes(mp.Select(2)); !equalStrings(got, want[:2]) {
+		t.Fatalf("Select(2) = %v, want %v", got, want[:2])
+	}
+	if got := mp.NextNonce("a", 0); got != 2 {
+		t.Fatalf("NextNonce = %d, want 2", got)
+	}
+}
+
+func TestMempoolRejectsDuplicates(t *testing.T) {
+	mp, _ := testMempool(DefaultMempoolConfig())
+	tx := pendingTransaction("a", 0, "0.0001")
+	addPending(t, mp, tx)
+
+	if err := mp.Add(tx); !errors.Is(err, ErrKnownTransaction) {
+		t.Fatalf("Add of a known transaction error = %v, want %v", err, ErrKnownTransaction)
+	}
+	if err := mp.Add(pendingTransaction("a", 0, "0.01")); !errors.Is(err, ErrNonceConflict) {
+		t.Fatalf("Add with a used nonce error = %v, want %v", err, ErrNonceConflict)
+	}
+	if n := mp.Len(); n != 1 {
+		t.Fatalf("Len = %d, want 1", n)
+	}
+}
+
+func TestMempoolPrunesOldTransactions(t *testing.T) {
+	mp, now := testMempool(MempoolConfig{MaxAge: time.Hour})
+	addPending(t, mp, pendingTransaction("a", 0, "0.0001"))
+	*now = now.Add(30 * time.Minute)
+	addPending(t, mp, pendingTransaction("a", 1, "0.0001"), pendingTransaction("b", 0, "0.0001"))
+
+	// a/0 устарела, a/1 без нее включить нельзя, b/0 еще не устарела
+	*now = now.Add(31 * time.Minute)
+	mp.Prune()
+	if got, want := hashes(mp.Pending()), []string{"b/0/0.0001"}; !equalStrings(got, want) {
+		t.Fatalf("Pending = %v, want %v", got, want)
+	}
+}
+
+func TestMempoolEviction(t *testing.T) {
+	mp, _ := testMempool(MempoolConfig{MaxSize: 3})
+	addPending(t, mp,
+		pendingTransaction("a", 0, "0.001"),
+		pendingTransaction("b", 0, "0.0001"),
+		pendingTransaction("b", 1, "0.0005"),
+	)
+
+	// Вытесняется последняя в очереди, а не самая дешевая b/0: иначе b/1 осталась бы без нее
+	addPending(t, mp, pendingTransaction("c", 0, "0.01"))
+	want := []string{"c/0/0.01", "a/0/0.001", "b/0/0.0001"}
+	if got := hashes(mp.Pending()); !equalStrings(got, want) {
+		t.Fatalf("Pending = %v, want %v", got, want)
+	}
+
+	if err := mp.Add(pendingTransaction("d", 0, "0.0001")); !errors.Is(err, ErrMempoolFull) {
+		t.Fatalf("Add of a cheaper transaction error = %v, want %v", err, ErrMempoolFull)
+	}
+}
+
+func TestMempoolEvictionKeepsIncomingSenderQueue(t *testing.T) {
+	mp, _ := testMempool(MempoolConfig{MaxSize: 3})
+	addPending(t, mp,
+		pendingTransaction("a", 0, "0.0001"),
+		pendingTransaction("a", 1, "0.0001"),
+		pendingTransaction("b", 0, "0.0001"),
+	)
+
+	// a/1 дешевле всех, но ее вытеснение оставило бы a/2 без предыдущего nonce
+	addPending(t, mp, pendingTransaction("a", 2, "0.001"))
+	want := []string{"a/0/0.0001", "a/1/0.0001", "a/2/0.001"}
+	if got := hashes(mp.PendingBySender("a")); !equalStrings(got, want) {
+		t.Fatalf("PendingBySender(a) = %v, want %v", got, want)
+	}
+	if got := mp.PendingBySender("b"); len(got) != 0 {
+		t.Fatalf("PendingBySender(b) = %v, want none", hashes(got))
+	}
+
+	// Если вытеснять можно только из очереди самого отправителя, пул полон
+	if err := mp.Add(pendingTransaction("a", 3, "0.01")); !errors.Is(err, ErrMempoolFull) {
+		t.Fatalf("Add error = %v, want %v", err, ErrMempoolFull)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Префиксы разделяют хеши листьев и внутренних узлов дерева, чтобы
+// внутренний узел нельзя было выдать за транзакцию
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// merkleLeaf возвращает хеш листа для хеша транзакции
+func merkleLeaf(txHash [32]byte) [32]byte {
+	data := make([]byte, 0, 1+len(txHash))
+	data = append(data, merkleLeafPrefix)
+	data = append(data, txHash[:]...)
+	return sha256.Sum256(data)
+}
+
+// merkleNode возвращает хеш внутреннего узла по двум потомкам
+func merkleNode(left, right [32]byte) [32]byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+	return sha256.Sum256(data)
+}
+
+// merkleLevels строит все уровни дерева, начиная с листьев. Узел без пары
+// переносится на следующий уровень без изменений, а не дублируется.
+func merkleLevels(txHashes [][32]byte) [][][32]byte {
+	if len(txHashes) == 0 {
+		return nil
+	}
+
+	level := make([][32]byte, len(txHashes))
+	for i, hash := range txHashes {
+		level[i] = merkleLeaf(hash)
+	}
+
+	levels := [][][32]byte{level}
+	for len(level) > 1 {
+		next := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels
+}
+
+// MerkleRoot вычисляет корень дерева Меркла по хешам транзакций;
+// для пустого списка корень состоит из нулей
+func MerkleRoot(txHashes [][32]byte) [32]byte {
+	levels := merkleLevels(txHashes)
+	if len(levels) == 0 {
+		return [32]byte{}
+	}
+	return levels[len(levels)-1][0]
+}
+
+// transactionHashes возвращает хеши транзакций блока, вычисленные по их содержимому
+func transactionHashes(transactions []Transaction) [][32]byte {
+	hashes := make([][32]byte, len(transactions))
+	for i := range transactions {
+		hashes[i] = transactions[i].Hashdo()
+	}
+	return hashes
+}
+
+var (
+	// ErrInvalidMerkleRoot возвращается, если корень в заголовке не совпадает с транзакциями блока
+	ErrInvalidMerkleRoot = errors.New("block merkle root mismatch")
+	// ErrProofUnavailable возвращается для транзакций из блоков без корня дерева Меркла
+	ErrProofUnavailable = errors.New("merkle proof is not available for this block version")
+	// ErrInvalidProof возвращается, если доказательство не сходится к заголовку блока
+	ErrInvalidProof = errors.New("invalid merkle proof")
+)
+
+// CalculateMerkleRoot возвращает hex-корень дерева Меркла транзакций блока
+func (b *Block) CalculateMerkleRoot() string {
+	root := MerkleRoot(transactionHashes(b.Transactions))
+	return hex.EncodeToString(root[:])
+}
+
+// checkHash проверяет версию блока, корень дерева Меркла и хеш блока.
+// Блоки старой версии принимаются здесь ради проверки сохраненной цепочки;
+// CommitBlock отдельно требует CurrentBlockVersion.
+func (b *Block) checkHash() error {
+	switch b.Version {
+	case BlockVersionLegacy:
+	case BlockVersionMerkle:
+		if b.MerkleRoot != b.CalculateMerkleRoot() {
+			return fmt.Errorf("%w: block %d", ErrInvalidMerkleRoot, b.Index)
+		}
+	default:
+		return fmt.Errorf("%w: block %d has version %d", ErrUnsupportedBlockVersion, b.Index, b.Version)
+	}
+
+	if b.Hash != b.CalculateHash() {
+		return fmt.Errorf("%w: block %d", ErrInvalidBlockHash, b.Index)
+	}
+	return nil
+}
+
+// MerkleProofStep — соседний узел на пути от листа к корню
+type MerkleProofStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"` // Соседний узел стоит слева от текущего
+}
+
+// TransactionProof доказывает включение транзакции в блок. Для проверки
+// нужен только заголовок: клиент, доверяющий хешу блока BlockHash,
+// может убедиться во включении транзакции без загрузки самого блока.
+type TransactionProof struct {
+	TxHash    string            `json:"txHash"`
+	BlockHash string            `json:"blockHash"`
+	Header    BlockHeader       `json:"header"`
+	Position  int               `json:"position"` // Порядковый номер транзакции в блоке
+	Path      []MerkleProofStep `json:"path"`     // Соседние узлы от листа к корню
+}
+
+// merklePath возвращает соседние узлы для листа index. Уровни, на которых
+// узел перенесен без пары, в путь не входят.
+func merklePath(txHashes [][32]byte, index int) []MerkleProofStep {
+	levels := merkleLevels(txHashes)
+
+	var path []MerkleProofStep
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			path = append(path, MerkleProofStep{
+				Hash: hex.EncodeToString(level[sibling][:]),
+				Left: sibling < index,
+			})
+		}
+		index /= 2
+	}
+	return path
+}
+
+// GetTransactionProof строит доказательство включения транзакции в блок
+func (bc *Blockchain) GetTransactionProof(txHash string) (*TransactionProof, error) {
+	record, err := LoadTransactionRecord(bc.db, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := bc.GetBlockByIndex(record.BlockIndex)
+	if err != nil {
+		return nil, err
+	}
+	if block.Version < BlockVersionMerkle {
+		return nil, fmt.Errorf("%w: block %d has version %d", ErrProofUnavailable, block.Index, block.Version)
+	}
+	if record.Position < 0 || record.Position >= len(block.Transactions) ||
+		block.Transactions[record.Position].Hash != txHash {
+		return nil, fmt.Errorf("%w: transaction %s not found in block %d", ErrChainCorrupted, txHash, block.Index)
+	}
+
+	return &TransactionProof{
+		TxHash:    txHash,
+		BlockHash: block.Hash,
+		Header:    block.Header(),
+		Position:  record.Position,
+		Path:      merklePath(transactionHashes(block.Transactions), record.Position),
+	}, nil
+}
+
+// VerifyTransactionProof проверяет, что путь из доказательства приводит от
+// хеша транзакции к корню в заголовке, а заголовок дает хеш BlockHash.
+// Вызывающий код должен отдельно убедиться, что BlockHash принадлежит
+// доверенной цепочке заголовков.
+func VerifyTransactionProof(proof *TransactionProof) error {
+	if proof == nil {
+		return fmt.Errorf("%w: proof is nil", ErrInvalidProof)
+	}
+	if proof.Header.Version != BlockVersionMerkle {
+		return fmt.Errorf("%w: header version %d", ErrProofUnavailable, proof.Header.Version)
+	}
+
+	txHash, err := decodeHash(proof.TxHash)
+	if err != nil {
+		return fmt.Errorf("%w: transaction hash: %v", ErrInvalidProof, err)
+	}
+
+	node := merkleLeaf(txHash)
+	for i, step := range proof.Path {
+		sibling, err := decodeHash(step.Hash)
+		if err != nil {
+			return fmt.Errorf("%w: path step %d: %v", ErrInvalidProof, i, err)
+		}
+		if step.Left {
+			node = merkleNode(sibling, node)
+		} else {
+			node = merkleNode(node, sibling)
+		}
+	}
+
+	if hex.EncodeToString(node[:]) != proof.Header.MerkleRoot {
+		return fmt.Errorf("%w: path does not lead to merkle root", ErrInvalidProof)
+	}
+
+	headerHash := proof.Header.Hash()
+	if hex.EncodeToString(headerHash[:]) != proof.BlockHash {
+		return fmt.Errorf("%w: header does not match block hash", ErrInvalidProof)
+	}
+	return nil
+}
+
+// decodeHash разбирает hex-запись 32-байтового хеша
+func decodeHash(s string) ([32]byte, error) {
+	var hash [32]byte
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return hash, err
+	}
+	if len(data) != len(hash) {
+		return hash, fmt.Errorf("expected %d bytes, got %d", len(hash), len(data))
+	}
+	copy(hash[:], data)
+	return hash, nil
+}
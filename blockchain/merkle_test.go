@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestMerkleRootKnownAnswer(t *testing.T) {
+	hashes := [][32]byte{
+		sha256.Sum256([]byte("a")),
+		sha256.Sum256([]byte("b")),
+		sha256.Sum256([]byte("c")),
+	}
+
+	// root = node(node(leaf(a), leaf(b)), leaf(c)); непарный лист переносится
+	// на следующий уровень без дублирования
+	const want = "cac3d448d4e20a2ad5eae1f500e63c2a7f9217cd14572ba7fd22e26dc1ec2648"
+	root := MerkleRoot(hashes)
+	if got := hex.EncodeToString(root[:]); got != want {
+		t.Fatalf("MerkleRoot = %s, want %s", got, want)
+	}
+
+	leaf := MerkleRoot(hashes[:1])
+	if got := hex.EncodeToString(leaf[:]); got != "a23bd5b06da9048238a65b3f1d9d0b9e15fae3dde262688e6489aa4c763d1820" {
+		t.Fatalf("single-leaf MerkleRoot = %s", got)
+	}
+
+	if empty := MerkleRoot(nil); empty != [32]byte{} {
+		t.Fatalf("empty MerkleRoot = %x, want zeros", empty)
+	}
+}
+
+func TestTransactionProof(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	var transactions []Transaction
+	for nonce := uint64(0); nonce < 5; nonce++ {
+		transactions = append(transactions,
+			signedTransfer(t, bc.Genesis().ChainID, user.address, validator.address, user.key, nonce))
+	}
+	if err := commitTestBlock(t, bc, validator, transactions); err != nil {
+		t.Fatalf("CommitBlock: %v", err)
+	}
+
+	for i, tx := range transactions {
+		proof, err := bc.GetTransactionProof(tx.Hash)
+		if err != nil {
+			t.Fatalf("GetTransactionProof(%d): %v", i, err)
+		}
+		if proof.Position != i {
+			t.Fatalf("proof %d position = %d", i, proof.Position)
+		}
+		if err := VerifyTransactionProof(proof); err != nil {
+			t.Fatalf("VerifyTransactionProof(%d): %v", i, err)
+		}
+	}
+
+	proof, err := bc.GetTransactionProof(transactions[2].Hash)
+	if err != nil {
+		t.Fatalf("GetTransactionProof: %v", err)
+	}
+
+	tampered := *proof
+	tampered.TxHash = transactions[3].Hash
+	if err := VerifyTransactionProof(&tampered); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("proof for another transaction: error = %v, want %v", err, ErrInvalidProof)
+	}
+
+	tampered = *proof
+	tampered.Path = append([]MerkleProofStep(nil), proof.Path...)
+	tampered.Path[0].Left = !tampered.Path[0].Left
+	if err := VerifyTransactionProof(&tampered); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("proof with swapped step: error = %v, want %v", err, ErrInvalidProof)
+	}
+
+	tampered = *proof
+	tampered.Header.Timestamp = "2000-01-01T00:00:00Z"
+	if err := VerifyTransactionProof(&tampered); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("proof with altered header: error = %v, want %v", err, ErrInvalidProof)
+	}
+}
@@ -0,0 +1,55 @@
+package blockchain
+
+import (
+	"fmt"
+	"strings"
+
+	AA "github.com/HHpCpp/AVAF/accounts"
+	avafdb "github.com/HHpCpp/AVAF/adb"
+)
+
+// MigrateAddresses переносит аккаунты, созданные со старой схемой адресов,
+// на каноничные адреса crypto.PubkeyToAddress. Вместе с аккаунтом
+// переносится стейк, а история транзакций копируется под новый адрес.
+// Все изменения записываются одним пакетом; повторный запуск ничего не меняет.
+func (bc *Blockchain) MigrateAddresses() ([]AA.AddressMigration, error) {
+	var migrations []AA.AddressMigration
+	err := bc.AccountManager.Update(func(batch *avafdb.Batch) error {
+		var err error
+		migrations, err = bc.AccountManager.MigrateAddresses(batch)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			if err := bc.StakingWallet.MigrateStake(batch, migration.OldAddress, migration.NewAddress); err != nil {
+				return err
+			}
+			if err := bc.copyAddressHistory(batch, migration.OldAddress, migration.NewAddress); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate addresses: %w", err)
+	}
+	return migrations, nil
+}
+
+// copyAddressHistory добавляет в пакет копию индекса истории старого адреса под новым
+func (bc *Blockchain) copyAddressHistory(batch *avafdb.Batch, oldAddress, newAddress string) error {
+	oldPrefix := addressHistoryPrefix(oldAddress)
+
+	iter := bc.db.NewPrefixIterator(oldPrefix)
+	defer iter.Release()
+
+	for iter.Next() {
+		cursor := strings.TrimPrefix(string(iter.Key()), oldPrefix)
+		batch.Save(addressHistoryPrefix(newAddress)+cursor, append([]byte(nil), iter.Value()...))
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	AA "github.com/HHpCpp/AVAF/accounts"
+	"github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/crypto"
+)
+
+// legacyAddress — адрес, полученный по старой схеме; по ключу его не восстановить
+const legacyAddress = "AVAFu00000000000000000000000000000000000001"
+
+// saveLegacyAccount сохраняет запись аккаунта owner под legacyAddress вместе
+// со стейком и записью истории, как их оставила старая схема адресов
+func saveLegacyAccount(t *testing.T, bc *Blockchain, owner testAccount) {
+	t.Helper()
+
+	data, err := json.Marshal(AA.Wallet{
+		Address:   legacyAddress,
+		Balance:   map[string]amount.Amount{FeeCurrency: amount.MustParse("5")},
+		PublicKey: hex.EncodeToString(crypto.MarshalPublicKey(&owner.key.PublicKey)),
+		Nonce:     2,
+	})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for key, value := range map[string]string{
+		"account_" + legacyAddress:             string(data),
+		"stake_" + legacyAddress:               "3",
+		addressHistoryKey(legacyAddress, 1, 0): "txhash",
+	} {
+		if err := bc.db.Save(key, []byte(value)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+}
+
+func TestMigrateAddresses(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+	owner := newTestAccount(t)
+	saveLegacyAccount(t, bc, owner)
+
+	migrations, err := bc.MigrateAddresses()
+	if err != nil {
+		t.Fatalf("MigrateAddresses: %v", err)
+	}
+	want := AA.AddressMigration{OldAddress: legacyAddress, NewAddress: owner.address}
+	if len(migrations) != 1 || migrations[0] != want {
+		t.Fatalf("migrations = %+v, want [%+v]", migrations, want)
+	}
+
+	if _, err := bc.db.Load("account_" + legacyAddress); !errors.Is(err, adb.ErrNotFound) {
+		t.Fatalf("old account record is still stored: %v", err)
+	}
+	// Старый адрес остается псевдонимом нового
+	for _, address := range []string{owner.address, legacyAddress} {
+		wallet, err := bc.AccountManager.LoadAccount(address)
+		if err != nil {
+			t.Fatalf("LoadAccount(%s): %v", address, err)
+		}
+		if wallet.Address != owner.address || wallet.Nonce != 2 || wallet.Balance[FeeCurrency] != amount.MustParse("5") {
+			t.Fatalf("LoadAccount(%s) = %+v, want the migrated account", address, wallet)
+		}
+	}
+
+	if stake, _ := bc.StakingWallet.GetStake(owner.address); stake != amount.MustParse("3") {
+		t.Fatalf("stake of %s = %s, want 3", owner.address, stake)
+	}
+	if stake, _ := bc.StakingWallet.GetStake(legacyAddress); !stake.IsZero() {
+		t.Fatalf("stake of %s = %s, want 0", legacyAddress, stake)
+	}
+	if value, err := bc.db.Load(addressHistoryKey(owner.address, 1, 0)); err != nil || string(value) != "txhash" {
+		t.Fatalf("history under the new address = %q, %v; want %q", value, err, "txhash")
+	}
+
+	// Повторный запуск ничего не меняет
+	if migrations, err := bc.MigrateAddresses(); err != nil || len(migrations) != 0 {
+		t.Fatalf("second MigrateAddresses = %+v, %v; want none", migrations, err)
+	}
+}
+
+func TestMigrateAddressesRejectsTakenAddress(t *testing.T) {
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+
+	// Каноничный адрес ключа уже занят аккаунтом генезиса
+	saveLegacyAccount(t, bc, user)
+
+	if _, err := bc.MigrateAddresses(); err == nil {
+		t.Fatal("MigrateAddresses succeeded over an existing account")
+	}
+	if _, err := bc.db.Load("account_" + legacyAddress); err != nil {
+		t.Fatalf("failed migration removed the old record: %v", err)
+	}
+	if stake, _ := bc.StakingWallet.GetStake(legacyAddress); stake != amount.MustParse("3") {
+		t.Fatalf("failed migration moved the stake: %s", stake)
+	}
+	if balance, _ := bc.AccountManager.GetBalance(user.address); balance[FeeCurrency] != amount.MustParse("100") {
+		t.Fatalf("failed migration changed %s: balance %s", user.address, balance[FeeCurrency])
+	}
+}
@@ -0,0 +1,221 @@
+package blockchain
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	pos "github.com/HHpCpp/AVAF/pos"
+)
+
+// DefaultSlotTime — длительность слота в DefaultGenesis; в каждом слоте
+// может быть создан не более чем один блок
+const DefaultSlotTime = 5 * time.Second
+
+// ProducerConfig задает параметры производства блоков
+type ProducerConfig struct {
+	MaxTransactions int  // Максимум транзакций из пула в одном блоке
+	ProduceEmpty    bool // Создавать блоки без транзакций
+}
+
+// DefaultProducerConfig возвращает параметры производства по умолчанию
+func DefaultProducerConfig() ProducerConfig {
+	return ProducerConfig{
+		MaxTransactions: MaxBlockTransactions,
+	}
+}
+
+var (
+	// ErrNotProposer возвращается, если выбранный на слот валидатор
+	// не зарегистрировал свой ключ на этом узле
+	ErrNotProposer = errors.New("slot proposer key is not available on this node")
+	// ErrSlotTaken возвращается, если блок текущего слота уже создан
+	ErrSlotTaken = errors.New("block for this slot already exists")
+	// ErrInvalidSlot возвращается для блока со слотом не после родителя или из будущего
+	ErrInvalidSlot = errors.New("invalid block slot")
+	// ErrWrongProposer возвращается, если блок создан не валидатором, выбранным на его слот
+	ErrWrongProposer = errors.New("block proposer is not the selected validator")
+)
+
+// Producer каждый слот выбирает валидатора по стейку, собирает блок из пула,
+// подписывает его ключом валидатора и добавляет в цепочку
+type Producer struct {
+	bc     *Blockchain
+	config ProducerConfig
+}
+
+// NewProducer создает производителя блоков для цепочки
+func NewProducer(bc *Blockchain, config ProducerConfig) *Producer {
+	if config.MaxTransactions <= 0 {
+		config.MaxTransactions = MaxBlockTransactions
+	}
+	return &Producer{bc: bc, config: config}
+}
+
+// Run производит блоки в начале каждого слота, пока не отменен ctx
+func (p *Producer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.bc.slotTime())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			block, err := p.ProduceBlock()
+			switch {
+			case errors.Is(err, ErrNotProposer), errors.Is(err, ErrNoPendingTransactions),
+				errors.Is(err, ErrSlotTaken):
+				// Слот принадлежит другому узлу или блок не нужен
+			case err != nil:
+				log.Printf("block production failed: %v", err)
+			default:
+				log.Printf("produced block %d (%s) by %s with %d transactions",
+					block.Index, block.Hash, block.Proposer, len(block.Transactions))
+			}
+		}
+	}
+}
+
+// ProduceBlock выполняет один слот: выбирает валидатора, собирает, подписывает
+// и фиксирует блок
+func (p *Producer) ProduceBlock() (*Block, error) {
+	assignment, err := p.bc.nextSlot()
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := p.bc.selectApplicable(assignment.prevBlock, p.config.MaxTransactions)
+	if len(transactions) == 0 && !p.config.ProduceEmpty {
+		return nil, ErrNoPendingTransactions
+	}
+
+	return p.bc.sealBlock(assignment, transactions)
+}
+
+// slotAssignment — слот, в котором этот узел может создать следующий блок
+type slotAssignment struct {
+	prevBlock  Block
+	slot       uint64
+	proposer   string
+	privateKey *ecdsa.PrivateKey
+}
+
+// nextSlot выбирает валидатора текущего слота и возвращает его ключ,
+// если он зарегистрирован на этом узле
+func (bc *Blockchain) nextSlot() (slotAssignment, error) {
+	prevBlock, err := bc.GetCurrentBlock()
+	if err != nil {
+		return slotAssignment{}, err
+	}
+
+	slot := bc.SlotAt(time.Now())
+	if slot <= prevBlock.Slot {
+		return slotAssignment{}, fmt.Errorf("%w: slot %d", ErrSlotTaken, slot)
+	}
+
+	proposer, err := bc.selectProposer(*prevBlock, slot)
+	if err != nil {
+		return slotAssignment{}, err
+	}
+
+	privateKey, ok := bc.StakingWallet.ValidatorKey(proposer)
+	if !ok {
+		return slotAssignment{}, fmt.Errorf("%w: %s", ErrNotProposer, proposer)
+	}
+
+	return slotAssignment{
+		prevBlock:  *prevBlock,
+		slot:       slot,
+		proposer:   proposer,
+		privateKey: privateKey,
+	}, nil
+}
+
+// sealBlock собирает блок слота, подписывает его ключом валидатора и фиксирует
+func (bc *Blockchain) sealBlock(assignment slotAssignment, transactions []Transaction) (*Block, error) {
+	prevBlock := assignment.prevBlock
+	block := NewBlock(prevBlock.Index+1, transactions, prevBlock.Hash)
+	block.Slot = assignment.slot
+	block.Proposer = assignment.proposer
+	if err := block.Sign(assignment.privateKey); err != nil {
+		return nil, err
+	}
+
+	if err := bc.CommitBlock(block); err != nil {
+		return nil, fmt.Errorf("failed to commit block %d: %w", block.Index, err)
+	}
+	return &block, nil
+}
+
+// selectProposer выбирает производителя блока слота slot, следующего за
+// parent, из набора валидаторов, сохраненного вместе с parent
+func (bc *Blockchain) selectProposer(parent Block, slot uint64) (string, error) {
+	validators, err := bc.StakingWallet.ValidatorSet(parent.Index)
+	if err != nil {
+		return "", fmt.Errorf("failed to select proposer: %w", err)
+	}
+	proposer, err := pos.SelectProposer(validators, parent.Hash, slot)
+	if err != nil {
+		return "", fmt.Errorf("failed to select proposer: %w", err)
+	}
+	return proposer, nil
+}
+
+// slotTime возвращает длительность слота цепочки. Она задается генезисом:
+// узлы с разной длительностью по-разному нумеровали бы слоты и выбирали валидаторов.
+func (bc *Blockchain) slotTime() time.Duration {
+	return bc.genesis.SlotTime()
+}
+
+// SlotAt возвращает номер слота для момента t; слот 0 начинается
+// во время генезис-блока
+func (bc *Blockchain) SlotAt(t time.Time) uint64 {
+	genesis, err := time.Parse(time.RFC3339, bc.genesis.Timestamp)
+	if err != nil || !t.After(genesis) {
+		return 0
+	}
+	return uint64(t.Sub(genesis) / bc.slotTime())
+}
+
+// VerifyProposer проверяет, что блок создан валидатором, которому по стейку
+// принадлежит его слот. Стейки берутся из набора валидаторов родительского
+// блока, поэтому любой узел и в любой момент получает тот же результат,
+// что и производитель блока.
+func (bc *Blockchain) VerifyProposer(block Block) error {
+	if block.Index <= 0 {
+		return fmt.Errorf("%w: block %d has no parent", ErrWrongProposer, block.Index)
+	}
+	parent, err := bc.GetBlockByIndex(block.Index - 1)
+	if err != nil {
+		return err
+	}
+	if parent.Hash != block.PrevHash {
+		return fmt.Errorf("%w: block %d does not link to %s", ErrBlockNotNext, block.Index, parent.Hash)
+	}
+	return bc.verifyProposer(block, *parent, time.Now())
+}
+
+func (bc *Blockchain) verifyProposer(block, parent Block, now time.Time) error {
+	if block.Slot <= parent.Slot {
+		return fmt.Errorf("%w: block %d slot %d is not after parent slot %d",
+			ErrInvalidSlot, block.Index, block.Slot, parent.Slot)
+	}
+	if current := bc.SlotAt(now); block.Slot > current {
+		return fmt.Errorf("%w: block %d slot %d is ahead of current slot %d",
+			ErrInvalidSlot, block.Index, block.Slot, current)
+	}
+
+	expected, err := bc.selectProposer(parent, block.Slot)
+	if err != nil {
+		return err
+	}
+	if block.Proposer != expected {
+		return fmt.Errorf("%w: block %d slot %d proposed by %s, expected %s",
+			ErrWrongProposer, block.Index, block.Slot, block.Proposer, expected)
+	}
+	return nil
+}
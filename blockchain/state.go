@@ -0,0 +1,240 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	AA "github.com/HHpCpp/AVAF/accounts"
+	avafdb "github.com/HHpCpp/AVAF/adb"
+	"github.com/HHpCpp/AVAF/amount"
+	pos "github.com/HHpCpp/AVAF/pos"
+)
+
+// FeeCurrency — валюта, в которой списывается комиссия за Afuel
+const FeeCurrency = "AVAF"
+
+var (
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrInvalidAmount        = errors.New("invalid transaction amount")
+	ErrInvalidSignature     = errors.New("invalid transaction signature")
+	ErrInvalidHash          = errors.New("transaction hash mismatch")
+	ErrStaleNonce           = errors.New("nonce already used")
+	ErrNonceGap             = errors.New("nonce is ahead of account nonce")
+	ErrDuplicateTransaction = errors.New("duplicate transaction")
+	ErrFeeTooLow            = errors.New("transaction fee is below network minimum")
+	ErrWrongChain           = errors.New("transaction is signed for another chain")
+	ErrInvalidTransaction   = errors.New("invalid transaction type or recipient")
+)
+
+// checkChainID проверяет, что транзакция подписана для этой сети
+func checkChainID(tx Transaction, chainID string) error {
+	if tx.ChainID != chainID {
+		return fmt.Errorf("%w: transaction chain %q, expected %q", ErrWrongChain, tx.ChainID, chainID)
+	}
+	return nil
+}
+
+// checkNonce сверяет nonce транзакции с ожидаемым nonce отправителя
+func checkNonce(tx Transaction, expected uint64) error {
+	switch {
+	case tx.Nonce < expected:
+		return fmt.Errorf("%w: %s sent nonce %d, account nonce is %d", ErrStaleNonce, tx.Sender, tx.Nonce, expected)
+	case tx.Nonce > expected:
+		return fmt.Errorf("%w: %s sent nonce %d, account nonce is %d", ErrNonceGap, tx.Sender, tx.Nonce, expected)
+	}
+	return nil
+}
+
+// stateDB накапливает изменения балансов в памяти, чтобы блок
+// применялся целиком или не применялся вовсе
+type stateDB struct {
+	am         *AA.AccountManager
+	sw         *pos.StakingWallet
+	chainID    string    // Идентификатор сети из генезиса
+	fees       FeeParams // Минимальная комиссия из генезиса
+	parent     int       // Блок, поверх которого применяются транзакции
+	wallets    map[string]*AA.Wallet
+	order      []string
+	seen       map[string]bool          // Хеши транзакций, уже примененных в этом состоянии
+	validators map[string]amount.Amount // Набор валидаторов; загружается при первом стейкинге
+	staked     []string                 // Адреса, стейк которых изменился
+}
+
+// newStateDB создает состояние поверх блока parent
+func (bc *Blockchain) newStateDB(parent int) *stateDB {
+	return &stateDB{
+		am:      bc.AccountManager,
+		sw:      bc.StakingWallet,
+		chainID: bc.genesis.ChainID,
+		fees:    bc.genesis.Fees,
+		parent:  parent,
+		wallets: make(map[string]*AA.Wallet),
+		seen:    make(map[string]bool),
+	}
+}
+
+// validatorSet возвращает набор валидаторов состояния, загружая при первом
+// обращении набор родительского блока
+func (s *stateDB) validatorSet() (map[string]amount.Amount, error) {
+	if s.validators == nil {
+		validators, err := s.sw.ValidatorSet(s.parent)
+		if err != nil {
+			return nil, err
+		}
+		s.validators = validators
+	}
+	return s.validators, nil
+}
+
+// wallet возвращает аккаунт из кеша состояния, загружая его при первом обращении.
+// Для адреса без записи в базе возвращает пустой аккаунт: получатель перевода
+// не обязан быть аккаунтом этого узла.
+func (s *stateDB) wallet(address string) (*AA.Wallet, error) {
+	if wallet, ok := s.wallets[address]; ok {
+		return wallet, nil
+	}
+
+	wallet, err := s.am.LoadAccount(address)
+	if errors.Is(err, avafdb.ErrNotFound) {
+		wallet, err = AA.Wallet{Address: address}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Balance == nil {
+		wallet.Balance = make(map[string]amount.Amount)
+	}
+
+	// Перенесенный адрес загружает аккаунт по каноничному адресу:
+	// оба адреса должны указывать на одну запись состояния
+	if cached, ok := s.wallets[wallet.Address]; ok {
+		s.wallets[address] = cached
+		return cached, nil
+	}
+	s.wallets[address] = &wallet
+	s.wallets[wallet.Address] = &wallet
+	s.order = append(s.order, wallet.Address)
+	return &wallet, nil
+}
+
+// applyTransaction проверяет nonce, списывает сумму и комиссию с отправителя
+// и зачисляет сумму получателю
+func (s *stateDB) applyTransaction(tx Transaction) error {
+	if s.seen[tx.Hash] {
+		return fmt.Errorf("%w: %s", ErrDuplicateTransaction, tx.Hash)
+	}
+	if err := checkChainID(tx, s.chainID); err != nil {
+		return err
+	}
+	if tx.Value.IsZero() {
+		return ErrInvalidAmount
+	}
+	if tx.Afuel < s.fees.Afuel || tx.AfuelPrice < s.fees.MinAfuelPrice {
+		return fmt.Errorf("%w: %d Afuel at %s, required %d Afuel at %s",
+			ErrFeeTooLow, tx.Afuel, tx.AfuelPrice, s.fees.Afuel, s.fees.MinAfuelPrice)
+	}
+	fee, err := tx.Fee()
+	if err != nil {
+		return fmt.Errorf("%w: fee: %v", ErrInvalidAmount, err)
+	}
+
+	switch tx.Type {
+	case TxTypeTransfer:
+	case TxTypeStake:
+		// Стейк хранится только в AVAF, а получатель — служебный адрес
+		if tx.Recipient != pos.StakingAddress || tx.ValueType != FeeCurrency {
+			return fmt.Errorf("%w: stake of %s to %s", ErrInvalidTransaction, tx.ValueType, tx.Recipient)
+		}
+	default:
+		return fmt.Errorf("%w: type %q", ErrInvalidTransaction, tx.Type)
+	}
+
+	sender, err := s.wallet(tx.Sender)
+	if err != nil {
+		return fmt.Errorf("failed to load sender %s: %w", tx.Sender, err)
+	}
+
+	if err := checkNonce(tx, sender.Nonce); err != nil {
+		return err
+	}
+
+	// Комиссия всегда списывается в AVAF, сумма — в валюте перевода
+	debits := map[string]amount.Amount{tx.ValueType: tx.Value}
+	if debits[FeeCurrency], err = debits[FeeCurrency].Add(fee); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+	}
+
+	senderBalance := make(map[string]amount.Amount, len(debits))
+	for currency, debit := range debits {
+		balance, err := sender.Balance[currency].Sub(debit)
+		if err != nil {
+			return fmt.Errorf("%w: %s has %s %s, required %s",
+				ErrInsufficientFunds, tx.Sender, sender.Balance[currency], currency, debit)
+		}
+		senderBalance[currency] = balance
+	}
+
+	if tx.Type == TxTypeStake {
+		// Стейк зачисляется на каноничный адрес отправителя
+		validators, err := s.validatorSet()
+		if err != nil {
+			return err
+		}
+		stake, err := validators[sender.Address].Add(tx.Value)
+		if err != nil {
+			return fmt.Errorf("failed to increase stake of %s: %w", sender.Address, err)
+		}
+		validators[sender.Address] = stake
+		s.staked = append(s.staked, sender.Address)
+	} else {
+		recipient, err := s.wallet(tx.Recipient)
+		if err != nil {
+			return fmt.Errorf("failed to load recipient %s: %w", tx.Recipient, err)
+		}
+		credited, err := recipient.Balance[tx.ValueType].Add(tx.Value)
+		if err != nil {
+			return fmt.Errorf("failed to credit %s: %w", tx.Recipient, err)
+		}
+		recipient.Balance[tx.ValueType] = credited
+	}
+
+	for currency, balance := range senderBalance {
+		sender.Balance[currency] = balance
+	}
+	sender.Nonce++
+	s.seen[tx.Hash] = true
+	return nil
+}
+
+// stage добавляет балансы и nonce измененных аккаунтов и стейки в пакет изменений
+func (s *stateDB) stage(batch *avafdb.Batch) error {
+	for _, address := range s.order {
+		if err := s.am.PutAccountState(batch, *s.wallets[address]); err != nil {
+			return err
+		}
+	}
+	for _, address := range s.staked {
+		s.sw.PutStake(batch, address, s.validators[address])
+	}
+	return nil
+}
+
+// stageTransactions проверяет подписи, хеши и применимость транзакций блока
+// и добавляет новые балансы в пакет. Если хотя бы одна транзакция не
+// проходит проверку, в пакет ничего не добавляется.
+// Возвращает состояние после блока для записи набора валидаторов.
+func (bc *Blockchain) stageTransactions(batch *avafdb.Batch, parent int, transactions []Transaction) (*stateDB, error) {
+	state := bc.newStateDB(parent)
+	for i, tx := range transactions {
+		if err := bc.checkNotCommitted(tx.Hash); err != nil {
+			return nil, fmt.Errorf("transaction %d rejected: %w", i, err)
+		}
+		if err := bc.verifyTransactionSignature(tx); err != nil {
+			return nil, fmt.Errorf("transaction %d (%s) rejected: %w", i, tx.Hash, err)
+		}
+		if err := state.applyTransaction(tx); err != nil {
+			return nil, fmt.Errorf("transaction %d (%s) rejected: %w", i, tx.Hash, err)
+		}
+	}
+	return state, state.stage(batch)
+}
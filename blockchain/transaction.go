@@ -5,48 +5,167 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
 	"time"
+
+	"github.com/HHpCpp/AVAF/amount"
+)
+
+// Стандартные значения комиссии: 1000 Afuel по 0.0001 AVAF
+const (
+	DefaultAfuel      uint64        = 1000
+	DefaultAfuelPrice amount.Amount = 100_000
+)
+
+// Типы транзакций
+const (
+	// TxTypeTransfer — перевод получателю
+	TxTypeTransfer = "transfer"
+	// TxTypeStake — перевод суммы в стейк отправителя; получатель — pos.StakingAddress
+	TxTypeStake = "stake"
 )
 
 type Transaction struct {
+	Hash       string        `json:"hash"`
+	ChainID    string        `json:"chainId,omitempty"` // Сеть, для которой подписана транзакция
+	Type       string        `json:"type"`              // TxTypeTransfer или TxTypeStake
+	Sender     string        `json:"from"`              // Адрес отправителя
+	Recipient  string        `json:"to"`                // Адрес получателя
+	ValueType  string        `json:"valueType"`         // AVAF
+	Value      amount.Amount `json:"value"`             // Количество в nano-AVAF
+	Afuel      uint64        `json:"afuel"`             // Единицы вычислительной работы
+	AfuelPrice amount.Amount `json:"afuelPrice"`        // Цена единицы Afuel в nano-AVAF
+	Data       string        `json:"data"`              // Сообщение
+	Nonce      uint64        `json:"nonce"`             // Порядковый номер транзакции отправителя
+	Signature  string        `json:"signature"`
+	Timestamp  string        `json:"timestamp"`
+
+	legacy *legacyTransaction // Исходная запись транзакции старого формата
+}
+
+// legacyTransaction — транзакция в формате до перехода на nano-AVAF, nonce
+// и идентификатор сети. Поля, их порядок и типы заморожены: по ним считаются
+// хеш такой транзакции и хеш блока версии BlockVersionLegacy.
+type legacyTransaction struct {
 	Hash       string  `json:"hash"`
-	Type       string  `json:"type"`      // transfer/smartcontract
-	Sender     string  `json:"from"`      // Адрес отправителя
-	Recipient  string  `json:"to"`        // Адрес получателя
-	ValueType  string  `json:"valueType"` // AVAF
-	Value      float64 `json:"value"`     // Количество
-	Afuel      float64 `json:"afuel"`     // Единицы вычислительной работы
+	Type       string  `json:"type"`
+	Sender     string  `json:"from"`
+	Recipient  string  `json:"to"`
+	ValueType  string  `json:"valueType"`
+	Value      float64 `json:"value"`
+	Afuel      float64 `json:"afuel"`
 	AfuelPrice float64 `json:"afuelPrice"`
-	Data       string  `json:"data"` // Сообщение
+	Data       string  `json:"data"`
 	Signature  string  `json:"signature"`
 	Timestamp  string  `json:"timestamp"`
 }
 
-func Ntr(sender, recipient string, amount float64, data string) (*Transaction, error) {
+// hash возвращает хеш транзакции старого формата
+func (t *legacyTransaction) hash() [32]byte {
+	data := fmt.Sprintf(
+		"%s-%s-%s-%s-%.18f-%.18f-%.18f-%s-%s",
+		t.Type,
+		t.Sender,
+		t.Recipient,
+		t.ValueType,
+		t.Value,
+		t.Afuel,
+		t.AfuelPrice,
+		t.Data,
+		t.Timestamp,
+	)
+	return sha256.Sum256([]byte(data))
+}
+
+// UnmarshalJSON читает транзакцию любого формата. Запись без полей nonce
+// и chainId — транзакция старого формата: она сохраняется как есть, чтобы
+// ее хеш и подпись проверялись по исходным значениям float64. Идентификатора
+// сети у нее нет, поэтому в новые блоки и пул она не принимается.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	type transaction Transaction
+	var decoded transaction
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	_, hasNonce := fields["nonce"]
+	_, hasChainID := fields["chainId"]
+
+	*t = Transaction(decoded)
+	if !hasNonce && !hasChainID {
+		var legacy legacyTransaction
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		t.legacy = &legacy
+	}
+	return nil
+}
+
+// MarshalJSON записывает транзакцию старого формата в исходном виде,
+// чтобы повторное сохранение не меняло ее хеш
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	if t.legacy != nil {
+		return json.Marshal(t.legacy)
+	}
+	type transaction Transaction
+	return json.Marshal(transaction(t))
+}
+
+// legacyRecord возвращает транзакцию в виде legacyTransaction: исходную
+// запись для транзакции старого формата, иначе поля с суммами в float64
+func (t *Transaction) legacyRecord() legacyTransaction {
+	if t.legacy != nil {
+		return *t.legacy
+	}
+	return legacyTransaction{
+		Hash:       t.Hash,
+		Type:       t.Type,
+		Sender:     t.Sender,
+		Recipient:  t.Recipient,
+		ValueType:  t.ValueType,
+		Value:      amountFloat(t.Value),
+		Afuel:      float64(t.Afuel),
+		AfuelPrice: amountFloat(t.AfuelPrice),
+		Data:       t.Data,
+		Signature:  t.Signature,
+		Timestamp:  t.Timestamp,
+	}
+}
+
+func amountFloat(a amount.Amount) float64 {
+	f, _ := strconv.ParseFloat(a.String(), 64)
+	return f
+}
+
+func Ntr(chainID, sender, recipient string, value amount.Amount, nonce uint64, data string) (*Transaction, error) {
 	if sender == recipient {
 		return nil, errors.New("sender and recipient cannot be the same")
 	}
 
-	if amount <= 0 {
+	if value.IsZero() {
 		return nil, errors.New("amount must be greater than 0")
 	}
 
-	// Стандартные значения комиссии
-	afuel := 1000.0
-	afuelPrice := 0.0001
-
 	tx := &Transaction{
-		Type:       "transfer",
+		ChainID:    chainID,
+		Type:       TxTypeTransfer,
 		Sender:     sender,
 		Recipient:  recipient,
 		ValueType:  "AVAF",
-		Value:      amount,
-		Afuel:      afuel,
-		AfuelPrice: afuelPrice,
+		Value:      value,
+		Afuel:      DefaultAfuel,
+		AfuelPrice: DefaultAfuelPrice,
 		Data:       data,
+		Nonce:      nonce,
 		Timestamp:  time.Now().UTC().Format(time.RFC3339), // Добавляем текущее время
 	}
 
@@ -58,16 +177,28 @@ func Ntr(sender, recipient string, amount float64, data string) (*Transaction, e
 
 func (t *Transaction) Sign(privateKey *ecdsa.PrivateKey) error {
 	hash := t.Hashdo()
-	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
+	signature, err := signHash(privateKey, hash[:])
 	if err != nil {
 		return fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
-	signature := append(r.Bytes(), s.Bytes()...)
-	t.Signature = hex.EncodeToString(signature)
+	t.Signature = signature
 	return nil
 }
 
+// signHash подписывает хеш и возвращает hex(r || s), где r и s дополнены до 32 байт
+func signHash(privateKey *ecdsa.PrivateKey, hash []byte) (string, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, hash)
+	if err != nil {
+		return "", err
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return hex.EncodeToString(signature), nil
+}
+
 func (t *Transaction) Verify(publicKey *ecdsa.PublicKey) (bool, error) {
 	signature, err := hex.DecodeString(t.Signature)
 	if err != nil {
@@ -85,18 +216,34 @@ func (t *Transaction) Verify(publicKey *ecdsa.PublicKey) (bool, error) {
 	return ecdsa.Verify(publicKey, hash[:], r, s), nil
 }
 
+// Fee возвращает комиссию транзакции в AVAF (Afuel * AfuelPrice)
+func (t *Transaction) Fee() (amount.Amount, error) {
+	return t.AfuelPrice.Mul(t.Afuel)
+}
+
+// Hashdo возвращает хеш подписываемых полей транзакции. Идентификатор сети
+// и nonce входят в хеш, поэтому подпись действительна только в одной сети и
+// только один раз. Хеш транзакции старого формата считается по ее исходной
+// записи (legacyTransaction), иначе ее подпись перестала бы сходиться.
 func (t *Transaction) Hashdo() [32]byte {
+	if t.legacy != nil {
+		return t.legacy.hash()
+	}
 	data := fmt.Sprintf(
-		"%s-%s-%s-%s-%.18f-%.18f-%.18f-%s-%s",
+		"%s-%s-%s-%s-%d-%d-%d-%s-%d-%s",
 		t.Type,
 		t.Sender,
 		t.Recipient,
 		t.ValueType,
-		t.Value,
+		t.Value.Nano(),
 		t.Afuel,
-		t.AfuelPrice,
+		t.AfuelPrice.Nano(),
 		t.Data,
+		t.Nonce,
 		t.Timestamp,
 	)
+	if t.ChainID != "" {
+		data = t.ChainID + "-" + data
+	}
 	return sha256.Sum256([]byte(data))
 }
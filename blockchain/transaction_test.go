@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/HHpCpp/AVAF/amount"
+)
+
+// Транзакция и ключ отправителя из базы, созданной до перехода на nano-AVAF
+// и nonce: суммы хранятся числами, идентификатора сети и nonce нет
+const (
+	legacyTransactionJSON = `{"hash":"735aa3b4e0f0d763244a05c2c1365f3cb2ec7f4699261d905f21dd431d513517","type":"transfer","from":"AVAFu3f093cd146875230446bf546f4777196e5b2617e","to":"AVAFub17952f6191603a7b3d077cbae585dbd47e818c0","valueType":"AVAF","value":10,"afuel":1000,"afuelPrice":0.0001,"data":"test","signature":"cdcf885bf51ef2b82a476775d828cfa4ddf33423124ab2c8897590570b998797e26610971d95d682d3d9a05ce5a054c9fa1a65d9007533defb650121f75e30e3","timestamp":"2026-10-17T18:28:29Z"}`
+	legacySenderPublicKey = "0c78b45485d8329ac286ce712e14824e1c6360cbd3bc7d528907cbb82311e61e327f9f5666c3390d864988b28d14d623d7f5acfa5df1ab1cbbf325d599aaa8c2"
+)
+
+func TestLegacyTransactionHash(t *testing.T) {
+	var tx Transaction
+	if err := json.Unmarshal([]byte(legacyTransactionJSON), &tx); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if tx.Value != amount.MustParse("10") || tx.AfuelPrice != amount.MustParse("0.0001") {
+		t.Fatalf("amounts = %s, %s; want 10, 0.0001", tx.Value, tx.AfuelPrice)
+	}
+
+	hash := tx.Hashdo()
+	if got := hex.EncodeToString(hash[:]); got != tx.Hash {
+		t.Fatalf("Hashdo = %s, want stored %s", got, tx.Hash)
+	}
+
+	publicKey, err := decodePublicKey(legacySenderPublicKey)
+	if err != nil {
+		t.Fatalf("decodePublicKey: %v", err)
+	}
+	if ok, err := tx.Verify(publicKey); err != nil || !ok {
+		t.Fatalf("Verify = %v, %v; want true", ok, err)
+	}
+
+	// Повторное сохранение не меняет запись, и хеш по-прежнему сходится
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(data) != legacyTransactionJSON {
+		t.Fatalf("Marshal = %s, want %s", data, legacyTransactionJSON)
+	}
+	var decoded Transaction
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Hashdo() != hash {
+		t.Fatal("hash changed after a JSON round trip")
+	}
+}
+
+func TestTransactionHashCoversChainIDAndNonce(t *testing.T) {
+	tx, err := NewTransaction("avaf-testnet", "AVAFfrom", "AVAFto", amount.MustParse("1"), 0, "")
+	if err != nil {
+		t.Fatalf("NewTransaction: %v", err)
+	}
+	hash := tx.Hashdo()
+
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded Transaction
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded.Hashdo() != hash {
+		t.Fatal("hash changed after a JSON round trip")
+	}
+
+	otherChain := *tx
+	otherChain.ChainID = "avaf-othernet"
+	otherNonce := *tx
+	otherNonce.Nonce = 1
+	if otherChain.Hashdo() == hash || otherNonce.Hashdo() == hash {
+		t.Fatal("hash does not depend on chain ID and nonce")
+	}
+}
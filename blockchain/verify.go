@@ -0,0 +1,149 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	AA "github.com/HHpCpp/AVAF/accounts"
+	avafdb "github.com/HHpCpp/AVAF/adb"
+)
+
+var (
+	// ErrBrokenLink возвращается, если PrevHash блока не совпадает с хешем предыдущего блока
+	ErrBrokenLink = errors.New("block does not link to previous block")
+	// ErrMissingBlock возвращается, если в хранилище нет блока с ожидаемым номером
+	ErrMissingBlock = errors.New("block is missing")
+)
+
+// IntegrityError описывает первое найденное нарушение целостности цепочки.
+// Err — одна из ошибок пакета (ErrMissingBlock, ErrBrokenLink,
+// ErrInvalidBlockHash, ErrInvalidSignature и т. д.), ее можно проверить через errors.Is.
+type IntegrityError struct {
+	BlockIndex int    // Номер блока с нарушением
+	TxHash     string // Хеш транзакции, если нарушение в транзакции
+	Err        error
+}
+
+func (e *IntegrityError) Error() string {
+	if e.TxHash != "" {
+		return fmt.Sprintf("block %d, transaction %s: %v", e.BlockIndex, e.TxHash, e.Err)
+	}
+	return fmt.Sprintf("block %d: %v", e.BlockIndex, e.Err)
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyStore проверяет цепочку в хранилище, ничего в него не записывая:
+// в отличие от NewBlockchainWithGenesis, не создает генезис-блок и не
+// дописывает индексы, а пропуски и разрывы возвращает как *IntegrityError.
+func VerifyStore(db avafdb.KVStore, genesis GenesisSpec, fromIndex, toIndex int) error {
+	if err := genesis.Validate(); err != nil {
+		return err
+	}
+
+	bc := &Blockchain{
+		AccountManager: AA.NewAccountManager(db),
+		genesis:        genesis,
+		db:             db,
+	}
+	return bc.Verify(fromIndex, toIndex)
+}
+
+// Verify заново проверяет сохраненные блоки с fromIndex по toIndex включительно:
+// отсутствие пропусков номеров, хеши и корни Меркла, связь через PrevHash,
+// подписи блоков и подписи всех транзакций. toIndex < 0 означает проверку
+// до последнего блока. Возвращает *IntegrityError для первого нарушения.
+func (bc *Blockchain) Verify(fromIndex, toIndex int) error {
+	lastIndex, err := GetLastBlockIndex(bc.db)
+	if err != nil {
+		return err
+	}
+	if lastIndex < 0 {
+		return &IntegrityError{BlockIndex: 0, Err: ErrMissingBlock}
+	}
+	if toIndex < 0 || toIndex > lastIndex {
+		toIndex = lastIndex
+	}
+	if fromIndex < 0 {
+		fromIndex = 0
+	}
+	if fromIndex > toIndex {
+		return fmt.Errorf("invalid range: from %d to %d", fromIndex, toIndex)
+	}
+
+	// Хеш блока перед диапазоном нужен для проверки связи первого блока
+	var prevHash string
+	if fromIndex > 0 {
+		prevBlock, err := bc.loadStoredBlock(fromIndex - 1)
+		if err != nil {
+			return err
+		}
+		prevHash = prevBlock.Hash
+	}
+
+	for index := fromIndex; index <= toIndex; index++ {
+		block, err := bc.loadStoredBlock(index)
+		if err != nil {
+			return err
+		}
+		if err := bc.verifyStoredBlock(block, prevHash); err != nil {
+			var integrityErr *IntegrityError
+			if errors.As(err, &integrityErr) {
+				return err
+			}
+			return &IntegrityError{BlockIndex: index, Err: err}
+		}
+		prevHash = block.Hash
+	}
+	return nil
+}
+
+// loadStoredBlock загружает блок для проверки и сверяет его номер с ключом
+func (bc *Blockchain) loadStoredBlock(index int) (Block, error) {
+	block, err := LoadBlock(bc.db, index)
+	if errors.Is(err, avafdb.ErrNotFound) {
+		return Block{}, &IntegrityError{BlockIndex: index, Err: ErrMissingBlock}
+	}
+	if err != nil {
+		return Block{}, &IntegrityError{BlockIndex: index, Err: fmt.Errorf("%w: %v", ErrChainCorrupted, err)}
+	}
+	if block.Index != index {
+		return Block{}, &IntegrityError{
+			BlockIndex: index,
+			Err:        fmt.Errorf("%w: stored under index %d, has index %d", ErrChainCorrupted, index, block.Index),
+		}
+	}
+	return block, nil
+}
+
+// verifyStoredBlock проверяет один сохраненный блок; prevHash — хеш предыдущего блока
+func (bc *Blockchain) verifyStoredBlock(block Block, prevHash string) error {
+	if block.Index == 0 {
+		if err := bc.checkGenesis(block); err != nil {
+			return err
+		}
+	} else if block.PrevHash != prevHash {
+		return fmt.Errorf("%w: expected %s, got %s", ErrBrokenLink, prevHash, block.PrevHash)
+	}
+
+	if err := block.checkHash(); err != nil {
+		return err
+	}
+
+	// Блоки нового формата создаются только с подписью; у старых
+	// проверяем подпись, если она есть. Генезис-блок не подписывается.
+	if block.Index > 0 && (block.Version >= BlockVersionMerkle || block.Proposer != "") {
+		if err := bc.verifyBlockSignature(block); err != nil {
+			return err
+		}
+	}
+
+	for _, tx := range block.Transactions {
+		if err := bc.verifyTransactionSignature(tx); err != nil {
+			return &IntegrityError{BlockIndex: block.Index, TxHash: tx.Hash, Err: err}
+		}
+	}
+	return nil
+}
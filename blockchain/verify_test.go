@@ -0,0 +1,180 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/HHpCpp/AVAF/adb"
+)
+
+// newVerifiedChain создает цепочку из генезиса и двух блоков с переводами
+func newVerifiedChain(t *testing.T) (*Blockchain, testAccount) {
+	t.Helper()
+
+	validator, user := newTestAccount(t), newTestAccount(t)
+	bc := newTestChain(t, validator, user)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx := signedTransfer(t, bc.Genesis().ChainID, user.address, validator.address, user.key, nonce)
+		if err := commitTestBlock(t, bc, validator, []Transaction{tx}); err != nil {
+			t.Fatalf("CommitBlock: %v", err)
+		}
+	}
+	if err := bc.Verify(0, -1); err != nil {
+		t.Fatalf("Verify of an intact chain: %v", err)
+	}
+	return bc, validator
+}
+
+// storeBlock перезаписывает блок в базе под ключом index
+func storeBlock(t *testing.T, bc *Blockchain, index int, block Block) {
+	t.Helper()
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := bc.db.Save(fmt.Sprintf("block_%d", index), data); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+// loadBlock загружает сохраненный блок
+func loadBlock(t *testing.T, bc *Blockchain, index int) Block {
+	t.Helper()
+	block, err := LoadBlock(bc.db, index)
+	if err != nil {
+		t.Fatalf("LoadBlock: %v", err)
+	}
+	return block
+}
+
+// resign пересчитывает корень Меркла и хеш блока и заново подписывает его,
+// как сделал бы валидатор, подделывающий собственный блок
+func resign(t *testing.T, block *Block, validator testAccount) {
+	t.Helper()
+	block.MerkleRoot = block.CalculateMerkleRoot()
+	if err := block.Sign(validator.key); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+}
+
+func TestVerifyReportsIntegrityErrors(t *testing.T) {
+	for name, tt := range map[string]struct {
+		corrupt func(t *testing.T, bc *Blockchain, validator testAccount)
+		index   int
+		tx      bool
+		want    error
+	}{
+		"missing block": {
+			corrupt: func(t *testing.T, bc *Blockchain, _ testAccount) {
+				if err := bc.db.Delete("block_1"); err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+			},
+			index: 1, want: ErrMissingBlock,
+		},
+		"block under another index": {
+			corrupt: func(t *testing.T, bc *Blockchain, _ testAccount) {
+				storeBlock(t, bc, 1, loadBlock(t, bc, 2))
+			},
+			index: 1, want: ErrChainCorrupted,
+		},
+		"altered transaction": {
+			corrupt: func(t *testing.T, bc *Blockchain, _ testAccount) {
+				block := loadBlock(t, bc, 2)
+				block.Transactions[0].Data = "altered"
+				storeBlock(t, bc, 2, block)
+			},
+			index: 2, want: ErrInvalidMerkleRoot,
+		},
+		"altered header": {
+			corrupt: func(t *testing.T, bc *Blockchain, _ testAccount) {
+				block := loadBlock(t, bc, 1)
+				block.Timestamp = "2000-01-01T00:00:00Z"
+				storeBlock(t, bc, 1, block)
+			},
+			index: 1, want: ErrInvalidBlockHash,
+		},
+		"broken link": {
+			corrupt: func(t *testing.T, bc *Blockchain, validator testAccount) {
+				block := loadBlock(t, bc, 2)
+				block.PrevHash = loadBlock(t, bc, 0).Hash
+				resign(t, &block, validator)
+				storeBlock(t, bc, 2, block)
+			},
+			index: 2, want: ErrBrokenLink,
+		},
+		"foreign block signature": {
+			corrupt: func(t *testing.T, bc *Blockchain, _ testAccount) {
+				block := loadBlock(t, bc, 1)
+				resign(t, &block, newTestAccount(t))
+				storeBlock(t, bc, 1, block)
+			},
+			index: 1, want: ErrInvalidBlockSignature,
+		},
+		"forged transaction signature": {
+			corrupt: func(t *testing.T, bc *Blockchain, validator testAccount) {
+				block := loadBlock(t, bc, 2)
+				forged := signedTransfer(t, bc.Genesis().ChainID, block.Transactions[0].Sender, validator.address, newTestAccount(t).key, 1)
+				block.Transactions[0] = forged
+				resign(t, &block, validator)
+				storeBlock(t, bc, 2, block)
+			},
+			index: 2, tx: true, want: ErrInvalidSignature,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bc, validator := newVerifiedChain(t)
+			tt.corrupt(t, bc, validator)
+
+			err := bc.Verify(0, -1)
+			var integrityErr *IntegrityError
+			if !errors.As(err, &integrityErr) {
+				t.Fatalf("Verify error = %v, want *IntegrityError", err)
+			}
+			if !errors.Is(err, tt.want) || integrityErr.BlockIndex != tt.index {
+				t.Fatalf("Verify error = %v, want %v at block %d", err, tt.want, tt.index)
+			}
+			if tt.tx != (integrityErr.TxHash != "") {
+				t.Fatalf("Verify error = %v, transaction hash %q", err, integrityErr.TxHash)
+			}
+		})
+	}
+}
+
+func TestVerifyRange(t *testing.T) {
+	bc, _ := newVerifiedChain(t)
+
+	// Поврежденный блок 2 не мешает проверке блоков до него
+	block := loadBlock(t, bc, 2)
+	block.Timestamp = "2000-01-01T00:00:00Z"
+	storeBlock(t, bc, 2, block)
+
+	if err := bc.Verify(0, 1); err != nil {
+		t.Fatalf("Verify(0, 1): %v", err)
+	}
+	if err := bc.Verify(2, 2); !errors.Is(err, ErrInvalidBlockHash) {
+		t.Fatalf("Verify(2, 2) error = %v, want %v", err, ErrInvalidBlockHash)
+	}
+
+	var integrityErr *IntegrityError
+	if err := bc.Verify(2, 1); err == nil || errors.As(err, &integrityErr) {
+		t.Fatalf("Verify(2, 1) error = %v, want a range error", err)
+	}
+}
+
+func TestVerifyStoreDoesNotWrite(t *testing.T) {
+	db := adb.NewMemoryDB()
+	err := VerifyStore(db, DefaultGenesis(), 0, -1)
+	var integrityErr *IntegrityError
+	if !errors.As(err, &integrityErr) || !errors.Is(err, ErrMissingBlock) || integrityErr.BlockIndex != 0 {
+		t.Fatalf("VerifyStore of an empty store error = %v, want %v at block 0", err, ErrMissingBlock)
+	}
+
+	iter := db.NewIterator()
+	defer iter.Release()
+	if iter.Next() {
+		t.Fatalf("VerifyStore wrote %q", iter.Key())
+	}
+}
@@ -5,14 +5,17 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 
-	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
 )
 
 // CipherParams содержит параметры шифрования
@@ -20,58 +23,125 @@ type CipherParams struct {
 	IV string `json:"iv"`
 }
 
-// KDFParams содержит параметры KDF-функции
+// KDFParams содержит параметры KDF-функции: N, R, P — для scrypt,
+// C и PRF — для pbkdf2, T, M и P — для argon2id
 type KDFParams struct {
 	DKLen int    `json:"dklen"`
-	N     int    `json:"n"`
-	P     int    `json:"p"`
-	R     int    `json:"r"`
+	N     int    `json:"n,omitempty"`
+	P     int    `json:"p,omitempty"`
+	R     int    `json:"r,omitempty"`
+	C     int    `json:"c,omitempty"`
+	PRF   string `json:"prf,omitempty"`
+	T     int    `json:"t,omitempty"` // Число проходов argon2id
+	M     int    `json:"m,omitempty"` // Память argon2id в КиБ
 	Salt  string `json:"salt"`
 }
 
-// CryptoJSON хранит зашифрованные данные и параметры
+// CryptoJSON хранит зашифрованные данные и параметры (раздел crypto
+// Web3 Secret Storage v3)
 type CryptoJSON struct {
 	Cipher       string       `json:"cipher"`
-	CipherCode   string       `json:"ciphercode"` // Переименовано из CipherText
+	CipherText   string       `json:"ciphertext,omitempty"` // Шифротекст v3
+	CipherCode   string       `json:"ciphercode,omitempty"` // Шифротекст записей aes-128-cbc
 	CipherParams CipherParams `json:"cipherparams"`
 	KDF          string       `json:"kdf"`
 	KDFParams    KDFParams    `json:"kdfparams"`
 	MAC          string       `json:"mac"`
 }
 
-func PubkeyToAddress(pubKey ecdsa.PublicKey) string {
-	// Конкатенируем X и Y координаты публичного ключа
-	pubBytes := append(pubKey.X.Bytes(), pubKey.Y.Bytes()...)
+// AddressPrefix — префикс всех адресов аккаунтов AVAF
+const AddressPrefix = "AVAFu"
+
+// PublicKeySize — длина публичного ключа P-256 в формате X || Y
+const PublicKeySize = 64
 
-	// Хешируем публичный ключ с помощью SHA-256
-	hash := sha256.Sum256(pubBytes)
+// PubkeyToAddress — единственная функция получения адреса из публичного ключа:
+// AddressPrefix + последние 20 байт Keccak-256 от MarshalPublicKey
+func PubkeyToAddress(pubKey ecdsa.PublicKey) string {
+	// Хешируем публичный ключ с помощью Keccak-256
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(MarshalPublicKey(&pubKey))
 
 	// Берем последние 20 байт хеша (как в Ethereum)
-	addressBytes := hash[len(hash)-20:]
+	addressBytes := hash.Sum(nil)[12:]
 
 	// Преобразуем байты в hex-строку
-	return "AVAFu" + hex.EncodeToString(addressBytes)
+	return AddressPrefix + hex.EncodeToString(addressBytes)
+}
+
+// MarshalPublicKey кодирует ключ как X || Y, дополняя каждую координату до 32 байт
+func MarshalPublicKey(pubKey *ecdsa.PublicKey) []byte {
+	data := make([]byte, PublicKeySize)
+	pubKey.X.FillBytes(data[:PublicKeySize/2])
+	pubKey.Y.FillBytes(data[PublicKeySize/2:])
+	return data
+}
+
+// UnmarshalPublicKey разбирает ключ P-256 из X || Y. Ключи, сохраненные раньше
+// без дополнения координат нулями, короче 64 байт; для них выбирается
+// единственное разбиение, при котором точка лежит на кривой.
+func UnmarshalPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	if len(data) == PublicKeySize {
+		return newPublicKey(data[:PublicKeySize/2], data[PublicKeySize/2:])
+	}
+	if len(data) > PublicKeySize || len(data) < PublicKeySize/2 {
+		return nil, fmt.Errorf("invalid public key length %d", len(data))
+	}
+
+	var found *ecdsa.PublicKey
+	for split := len(data) - PublicKeySize/2; split <= PublicKeySize/2; split++ {
+		pubKey, err := newPublicKey(data[:split], data[split:])
+		if err != nil {
+			continue
+		}
+		if found != nil {
+			return nil, errors.New("ambiguous legacy public key")
+		}
+		found = pubKey
+	}
+	if found == nil {
+		return nil, errors.New("public key is not on curve")
+	}
+	return found, nil
+}
+
+// newPublicKey собирает ключ P-256 из координат и проверяет, что точка на кривой
+func newPublicKey(x, y []byte) (*ecdsa.PublicKey, error) {
+	pubKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+	if !pubKey.Curve.IsOnCurve(pubKey.X, pubKey.Y) {
+		return nil, errors.New("public key is not on curve")
+	}
+	return pubKey, nil
 }
 
-// EncryptData шифрует данные с использованием пароля
-func EncryptData(data []byte, password string) (*CryptoJSON, error) {
+// Шифры, которые умеет расшифровывать DecryptData
+const (
+	// CipherAES128CTR — шифр Web3 Secret Storage v3
+	CipherAES128CTR = "aes-128-ctr"
+	// CipherLegacyCBC — прежний формат: AES-128-CBC без дополнения и MAC на SHA-256
+	CipherLegacyCBC = "aes-128-cbc"
+)
+
+// EncryptData шифрует данные паролем по схеме Web3 Secret Storage v3:
+// ключ из KDF с параметрами options, AES-128-CTR и
+// MAC = Keccak-256(ключ[16:32] || шифротекст). Длина данных может быть любой.
+func EncryptData(data []byte, password string, options KDFOptions) (*CryptoJSON, error) {
 	// Генерируем соль
 	salt := make([]byte, 32)
 	if _, err := rand.Read(salt); err != nil {
 		return nil, fmt.Errorf("failed to generate salt: %w", err)
 	}
 
-	// Генерируем ключ с помощью scrypt
-	derivedKey, err := scrypt.Key(
-		[]byte(password),
-		salt,
-		262144, // N
-		8,      // R
-		1,      // P
-		32,     // DKLen
-	)
+	// Генерируем ключ; те же ограничения параметров, что и при расшифровке
+	params := options.Params
+	params.Salt = hex.EncodeToString(salt)
+	derivedKey, err := deriveKey(CryptoJSON{KDF: options.KDF, KDFParams: params}, password)
 	if err != nil {
-		return nil, fmt.Errorf("scrypt error: %w", err)
+		return nil, err
 	}
 
 	// Генерируем IV
@@ -81,55 +151,93 @@ func EncryptData(data []byte, password string) (*CryptoJSON, error) {
 	}
 
 	// Шифруем данные
-	block, err := aes.NewCipher(derivedKey[:16])
+	ciphertext, err := aesCTR(derivedKey[:16], iv, data)
 	if err != nil {
-		return nil, fmt.Errorf("aes error: %w", err)
+		return nil, err
 	}
 
-	mode := cipher.NewCBCEncrypter(block, iv)
-	ciphertext := make([]byte, len(data))
-	mode.CryptBlocks(ciphertext, data)
-
-	// Вычисляем MAC
-	mac := sha256.Sum256(append(derivedKey[16:], ciphertext...))
-
 	return &CryptoJSON{
-		Cipher:     "aes-128-cbc",
-		CipherCode: hex.EncodeToString(ciphertext),
+		Cipher:     CipherAES128CTR,
+		CipherText: hex.EncodeToString(ciphertext),
 		CipherParams: CipherParams{
 			IV: hex.EncodeToString(iv),
 		},
-		KDF: "scrypt",
-		KDFParams: KDFParams{
-			DKLen: 32,
-			N:     262144,
-			P:     1,
-			R:     8,
-			Salt:  hex.EncodeToString(salt),
-		},
-		MAC: hex.EncodeToString(mac[:]),
+		KDF:       options.KDF,
+		KDFParams: params,
+		MAC:       hex.EncodeToString(keccakMAC(derivedKey, ciphertext)),
 	}, nil
 }
 
-// DecryptData расшифровывает данные с использованием пароля
+// DecryptData расшифровывает данные с использованием пароля. Записи v3
+// (aes-128-ctr) и записи прежнего формата (aes-128-cbc) различаются по полю Cipher.
 func DecryptData(cryptoJSON CryptoJSON, password string) ([]byte, error) {
-	// Декодируем соль
-	salt, err := hex.DecodeString(cryptoJSON.KDFParams.Salt)
+	switch cryptoJSON.Cipher {
+	case CipherAES128CTR:
+		return decryptV3(cryptoJSON, password)
+	case CipherLegacyCBC:
+		return decryptLegacyCBC(cryptoJSON, password)
+	default:
+		return nil, fmt.Errorf("unsupported cipher %q", cryptoJSON.Cipher)
+	}
+}
+
+// decryptV3 расшифровывает раздел crypto Web3 Secret Storage v3
+func decryptV3(cryptoJSON CryptoJSON, password string) ([]byte, error) {
+	derivedKey, err := deriveKey(cryptoJSON, password)
 	if err != nil {
-		return nil, fmt.Errorf("salt decode error: %w", err)
+		return nil, err
 	}
 
+	ciphertext, err := hex.DecodeString(cryptoJSON.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("ciphertext decode error: %w", err)
+	}
+
+	// Проверяем MAC
+	expectedMAC, err := hex.DecodeString(cryptoJSON.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("mac decode error: %w", err)
+	}
+	if subtle.ConstantTimeCompare(keccakMAC(derivedKey, ciphertext), expectedMAC) != 1 {
+		return nil, errors.New("mac mismatch")
+	}
+
+	iv, err := hex.DecodeString(cryptoJSON.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("iv decode error: %w", err)
+	}
+	return aesCTR(derivedKey[:16], iv, ciphertext)
+}
+
+// keccakMAC вычисляет MAC v3: Keccak-256(ключ[16:32] || шифротекст)
+func keccakMAC(derivedKey, ciphertext []byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(derivedKey[16:32])
+	hash.Write(ciphertext)
+	return hash.Sum(nil)
+}
+
+// aesCTR шифрует или расшифровывает данные AES-128-CTR
+func aesCTR(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes error: %w", err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid iv length %d", len(iv))
+	}
+
+	out := make([]byte, len(data))
+	cipher.NewCTR(block, iv).XORKeyStream(out, data)
+	return out, nil
+}
+
+// decryptLegacyCBC расшифровывает записи, созданные до перехода на v3
+func decryptLegacyCBC(cryptoJSON CryptoJSON, password string) ([]byte, error) {
 	// Генерируем ключ
-	derivedKey, err := scrypt.Key(
-		[]byte(password),
-		salt,
-		cryptoJSON.KDFParams.N,
-		cryptoJSON.KDFParams.R,
-		cryptoJSON.KDFParams.P,
-		cryptoJSON.KDFParams.DKLen,
-	)
+	derivedKey, err := deriveKey(cryptoJSON, password)
 	if err != nil {
-		return nil, fmt.Errorf("scrypt error: %w", err)
+		return nil, err
 	}
 
 	// Декодируем ciphercode
@@ -137,9 +245,12 @@ func DecryptData(cryptoJSON CryptoJSON, password string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("ciphertext decode error: %w", err)
 	}
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("legacy ciphertext is not a multiple of the block size")
+	}
 
 	// Проверяем MAC
-	mac := sha256.Sum256(append(derivedKey[16:], ciphertext...))
+	mac := sha256.Sum256(append(append([]byte(nil), derivedKey[16:]...), ciphertext...))
 	expectedMAC, err := hex.DecodeString(cryptoJSON.MAC)
 	if err != nil {
 		return nil, fmt.Errorf("mac decode error: %w", err)
@@ -154,6 +265,9 @@ func DecryptData(cryptoJSON CryptoJSON, password string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("iv decode error: %w", err)
 	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("invalid iv length %d", len(iv))
+	}
 
 	// Расшифровываем данные
 	block, err := aes.NewCipher(derivedKey[:16])
@@ -0,0 +1,192 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// HardenedOffset — первый индекс усиленной (hardened) производной
+const HardenedOffset uint32 = 0x80000000
+
+// HDCoinType — тип монеты AVAF в пути BIP-44 ("AVAF" в ASCII).
+// В реестре SLIP-44 значение не зарегистрировано.
+const HDCoinType uint32 = 0x41564146
+
+// mnemonicEntropyBits — энтропия мнемоники: 256 бит дают 24 слова
+const mnemonicEntropyBits = 256
+
+// slip10Curve — ключ HMAC мастер-ключа SLIP-10 для кривой P-256
+var slip10Curve = []byte("Nist256p1 seed")
+
+var (
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	ErrHardenedPublic  = errors.New("cannot derive hardened child from public key")
+)
+
+// NewMnemonic генерирует мнемонику BIP-39 из 24 английских слов
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate entropy: %w", err)
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// MnemonicToSeed проверяет контрольную сумму мнемоники и возвращает
+// 64-байтовый seed BIP-39 для парольной фразы passphrase
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMnemonic, err)
+	}
+	return seed, nil
+}
+
+// ExtendedKey — ключ иерархии SLIP-10 для P-256. У публичного расширенного
+// ключа PrivateKey равен nil, и из него выводятся только обычные производные.
+type ExtendedKey struct {
+	PrivateKey *ecdsa.PrivateKey
+	PublicKey  *ecdsa.PublicKey
+	ChainCode  []byte
+}
+
+// NewMasterKey возвращает мастер-ключ SLIP-10 для seed BIP-39
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("invalid seed length %d", len(seed))
+	}
+
+	data := seed
+	for {
+		sum := hmacSHA512(slip10Curve, data)
+		if key, err := PrivateKeyFromBytes(sum[:32]); err == nil {
+			return &ExtendedKey{PrivateKey: key, PublicKey: &key.PublicKey, ChainCode: sum[32:]}, nil
+		}
+		// SLIP-10: недопустимый ключ — повторяем HMAC от полученного значения
+		data = sum
+	}
+}
+
+// Neuter возвращает публичную часть расширенного ключа
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	return &ExtendedKey{PublicKey: k.PublicKey, ChainCode: k.ChainCode}
+}
+
+// Child выводит дочерний ключ с индексом index по SLIP-10. Индексы от
+// HardenedOffset и выше дают усиленные производные и требуют приватный ключ.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	hardened := index >= HardenedOffset
+	if hardened && k.PrivateKey == nil {
+		return nil, ErrHardenedPublic
+	}
+
+	var data []byte
+	if hardened {
+		data = make([]byte, 33)
+		k.PrivateKey.D.FillBytes(data[1:])
+	} else {
+		data = elliptic.MarshalCompressed(elliptic.P256(), k.PublicKey.X, k.PublicKey.Y)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	curve := elliptic.P256()
+	n := curve.Params().N
+	for {
+		sum := hmacSHA512(k.ChainCode, data)
+		il := new(big.Int).SetBytes(sum[:32])
+		chainCode := sum[32:]
+
+		if il.Cmp(n) < 0 {
+			if k.PrivateKey != nil {
+				d := il.Add(il, k.PrivateKey.D)
+				d.Mod(d, n)
+				if d.Sign() != 0 {
+					keyBytes := make([]byte, 32)
+					d.FillBytes(keyBytes)
+					key, err := PrivateKeyFromBytes(keyBytes)
+					if err != nil {
+						return nil, err
+					}
+					return &ExtendedKey{PrivateKey: key, PublicKey: &key.PublicKey, ChainCode: chainCode}, nil
+				}
+			} else {
+				x, y := curve.ScalarBaseMult(sum[:32])
+				x, y = curve.Add(x, y, k.PublicKey.X, k.PublicKey.Y)
+				if x.Sign() != 0 || y.Sign() != 0 {
+					return &ExtendedKey{
+						PublicKey: &ecdsa.PublicKey{Curve: curve, X: x, Y: y},
+						ChainCode: chainCode,
+					}, nil
+				}
+			}
+		}
+
+		// SLIP-10: недопустимый дочерний ключ — I = HMAC(c, 0x01 || IR || index)
+		data = append([]byte{0x01}, chainCode...)
+		data = binary.BigEndian.AppendUint32(data, index)
+	}
+}
+
+// DerivePath выводит ключ по пути вида m/44'/0'/0'/0/1
+func (k *ExtendedKey) DerivePath(path string) (*ExtendedKey, error) {
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	key := k
+	for _, index := range indexes {
+		if key, err = key.Child(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// ParseDerivationPath разбирает путь BIP-32; апостроф или h обозначают
+// усиленный индекс
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %q: must start with m", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		offset := uint32(0)
+		if trimmed := strings.TrimRight(part, "'h"); trimmed != part {
+			if len(part)-len(trimmed) != 1 {
+				return nil, fmt.Errorf("invalid derivation path %q: bad component %q", path, part)
+			}
+			part, offset = trimmed, HardenedOffset
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedOffset {
+			return nil, fmt.Errorf("invalid derivation path %q: bad component %q", path, part)
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+	return indexes, nil
+}
+
+// HDAccountPath возвращает путь BIP-44 внешнего адреса с номером index
+// первого аккаунта AVAF: m/44'/HDCoinType'/0'/0/index
+func HDAccountPath(index uint32) string {
+	return fmt.Sprintf("m/44'/%d'/0'/0/%d", HDCoinType, index)
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Поддерживаемые значения поля kdf
+const (
+	KDFScrypt   = "scrypt"
+	KDFPBKDF2   = "pbkdf2"
+	KDFArgon2id = "argon2id"
+)
+
+// ErrKDFParams возвращается для параметров KDF вне допустимых пределов.
+// Пределы защищают узел от файлов ключей, расшифровка которых заняла бы
+// гигабайты памяти или часы процессорного времени.
+var ErrKDFParams = errors.New("kdf parameters out of accepted range")
+
+// Пределы параметров KDF, которые принимает DecryptData
+const (
+	maxDKLen       = 64
+	maxSaltLen     = 1024
+	maxScryptMem   = 1 << 30 // 128 * N * r байт
+	maxScryptWork  = 1 << 24 // N * r * p
+	maxScryptP     = 16
+	maxPBKDF2Iters = 10_000_000
+	maxArgon2Mem   = 1 << 20 // КиБ, то есть 1 ГиБ
+	maxArgon2Time  = 16
+	maxArgon2P     = 64
+)
+
+// KDFOptions выбирает функцию получения ключа и ее параметры для EncryptData.
+// Соль в Params не задается: она генерируется при каждом шифровании.
+type KDFOptions struct {
+	KDF    string
+	Params KDFParams
+}
+
+// KDFLight — быстрый scrypt для тестов и массового создания аккаунтов
+func KDFLight() KDFOptions {
+	return KDFOptions{KDF: KDFScrypt, Params: KDFParams{DKLen: 32, N: 4096, R: 8, P: 1}}
+}
+
+// KDFStandard — scrypt с параметрами Web3 Secret Storage по умолчанию
+func KDFStandard() KDFOptions {
+	return KDFOptions{KDF: KDFScrypt, Params: KDFParams{DKLen: 32, N: 262144, R: 8, P: 1}}
+}
+
+// KDFParanoid — scrypt с 1 ГиБ памяти для холодного хранения
+func KDFParanoid() KDFOptions {
+	return KDFOptions{KDF: KDFScrypt, Params: KDFParams{DKLen: 32, N: 1 << 20, R: 8, P: 1}}
+}
+
+// KDFArgon2 — argon2id с 64 МиБ памяти и тремя проходами (RFC 9106)
+func KDFArgon2() KDFOptions {
+	return KDFOptions{KDF: KDFArgon2id, Params: KDFParams{DKLen: 32, T: 3, M: 64 * 1024, P: 4}}
+}
+
+// deriveKey проверяет параметры KDF записи и получает из пароля ключ шифрования
+func deriveKey(cryptoJSON CryptoJSON, password string) ([]byte, error) {
+	params := cryptoJSON.KDFParams
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("salt decode error: %w", err)
+	}
+	if len(salt) == 0 || len(salt) > maxSaltLen {
+		return nil, fmt.Errorf("%w: salt length %d", ErrKDFParams, len(salt))
+	}
+	if params.DKLen < 32 || params.DKLen > maxDKLen {
+		return nil, fmt.Errorf("%w: dklen %d", ErrKDFParams, params.DKLen)
+	}
+
+	switch cryptoJSON.KDF {
+	case KDFScrypt:
+		if err := checkScryptParams(params); err != nil {
+			return nil, err
+		}
+		derivedKey, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P, params.DKLen)
+		if err != nil {
+			return nil, fmt.Errorf("scrypt error: %w", err)
+		}
+		return derivedKey, nil
+	case KDFPBKDF2:
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported pbkdf2 prf %q", params.PRF)
+		}
+		if params.C <= 0 || params.C > maxPBKDF2Iters {
+			return nil, fmt.Errorf("%w: pbkdf2 c=%d", ErrKDFParams, params.C)
+		}
+		return pbkdf2.Key([]byte(password), salt, params.C, params.DKLen, sha256.New), nil
+	case KDFArgon2id:
+		if params.T <= 0 || params.T > maxArgon2Time ||
+			params.M < 8*params.P || params.M > maxArgon2Mem ||
+			params.P <= 0 || params.P > maxArgon2P {
+			return nil, fmt.Errorf("%w: argon2id t=%d m=%d p=%d", ErrKDFParams, params.T, params.M, params.P)
+		}
+		return argon2.IDKey([]byte(password), salt, uint32(params.T), uint32(params.M), uint8(params.P), uint32(params.DKLen)), nil
+	default:
+		return nil, fmt.Errorf("unsupported kdf %q", cryptoJSON.KDF)
+	}
+}
+
+// checkScryptParams проверяет, что scrypt уложится в пределы памяти и работы
+func checkScryptParams(params KDFParams) error {
+	n, r, p := params.N, params.R, params.P
+	if n <= 1 || n&(n-1) != 0 || r <= 0 || p <= 0 || p > maxScryptP {
+		return fmt.Errorf("%w: scrypt n=%d r=%d p=%d", ErrKDFParams, n, r, p)
+	}
+	if uint64(n)*uint64(r) > maxScryptMem/128 || uint64(n)*uint64(r)*uint64(p) > maxScryptWork {
+		return fmt.Errorf("%w: scrypt n=%d r=%d p=%d", ErrKDFParams, n, r, p)
+	}
+	return nil
+}
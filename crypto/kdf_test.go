@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDataRoundTrip(t *testing.T) {
+	data := []byte("seed or private key of any length")
+	for name, options := range map[string]KDFOptions{
+		"scrypt":   KDFLight(),
+		"pbkdf2":   {KDF: KDFPBKDF2, Params: KDFParams{DKLen: 32, C: 1000, PRF: "hmac-sha256"}},
+		"argon2id": KDFArgon2(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			cryptoJSON, err := EncryptData(data, "password", options)
+			if err != nil {
+				t.Fatalf("EncryptData: %v", err)
+			}
+			if cryptoJSON.KDF != options.KDF || cryptoJSON.Cipher != CipherAES128CTR {
+				t.Fatalf("kdf %q, cipher %q; want %q, %q", cryptoJSON.KDF, cryptoJSON.Cipher, options.KDF, CipherAES128CTR)
+			}
+
+			decrypted, err := DecryptData(*cryptoJSON, "password")
+			if err != nil {
+				t.Fatalf("DecryptData: %v", err)
+			}
+			if !bytes.Equal(decrypted, data) {
+				t.Fatalf("DecryptData = %q, want %q", decrypted, data)
+			}
+			if _, err := DecryptData(*cryptoJSON, "wrong"); err == nil {
+				t.Fatal("DecryptData accepted a wrong password")
+			}
+
+			// Соль и IV новые при каждом шифровании
+			again, err := EncryptData(data, "password", options)
+			if err != nil {
+				t.Fatalf("EncryptData: %v", err)
+			}
+			if again.KDFParams.Salt == cryptoJSON.KDFParams.Salt || again.CipherParams.IV == cryptoJSON.CipherParams.IV {
+				t.Fatal("salt or IV reused")
+			}
+		})
+	}
+}
+
+func TestDecryptDataRejectsKDFParams(t *testing.T) {
+	scryptJSON, err := EncryptData([]byte("data"), "password", KDFLight())
+	if err != nil {
+		t.Fatalf("EncryptData: %v", err)
+	}
+	argonJSON, err := EncryptData([]byte("data"), "password", KDFArgon2())
+	if err != nil {
+		t.Fatalf("EncryptData: %v", err)
+	}
+	pbkdf2JSON, err := EncryptData([]byte("data"), "password",
+		KDFOptions{KDF: KDFPBKDF2, Params: KDFParams{DKLen: 32, C: 1000, PRF: "hmac-sha256"}})
+	if err != nil {
+		t.Fatalf("EncryptData: %v", err)
+	}
+
+	// Параметры, которые заставили бы узел потратить гигабайты памяти или
+	// часы процессора, отклоняются до запуска KDF
+	for name, tt := range map[string]struct {
+		base   *CryptoJSON
+		change func(p *KDFParams)
+	}{
+		"empty salt":           {scryptJSON, func(p *KDFParams) { p.Salt = "" }},
+		"short dklen":          {scryptJSON, func(p *KDFParams) { p.DKLen = 16 }},
+		"long dklen":           {scryptJSON, func(p *KDFParams) { p.DKLen = maxDKLen + 1 }},
+		"scrypt n not 2^k":     {scryptJSON, func(p *KDFParams) { p.N = 4000 }},
+		"scrypt memory":        {scryptJSON, func(p *KDFParams) { p.N = 1 << 22 }},
+		"scrypt work":          {scryptJSON, func(p *KDFParams) { p.N, p.R, p.P = 1<<20, 8, 4 }},
+		"scrypt p":             {scryptJSON, func(p *KDFParams) { p.P = maxScryptP + 1 }},
+		"scrypt r":             {scryptJSON, func(p *KDFParams) { p.R = 0 }},
+		"pbkdf2 no iterations": {pbkdf2JSON, func(p *KDFParams) { p.C = 0 }},
+		"pbkdf2 iterations":    {pbkdf2JSON, func(p *KDFParams) { p.C = maxPBKDF2Iters + 1 }},
+		"argon2id time":        {argonJSON, func(p *KDFParams) { p.T = maxArgon2Time + 1 }},
+		"argon2id memory":      {argonJSON, func(p *KDFParams) { p.M = maxArgon2Mem + 1 }},
+		"argon2id tiny memory": {argonJSON, func(p *KDFParams) { p.M = 8*p.P - 1 }},
+		"argon2id lanes":       {argonJSON, func(p *KDFParams) { p.P = 0 }},
+	} {
+		cryptoJSON := *tt.base
+		tt.change(&cryptoJSON.KDFParams)
+		if _, err := DecryptData(cryptoJSON, "password"); !errors.Is(err, ErrKDFParams) {
+			t.Fatalf("%s: DecryptData error = %v, want %v", name, err, ErrKDFParams)
+		}
+	}
+
+	// Те же пределы действуют при шифровании
+	if _, err := EncryptData([]byte("data"), "password", KDFOptions{KDF: KDFScrypt, Params: KDFParams{DKLen: 32, N: 1 << 22, R: 8, P: 1}}); !errors.Is(err, ErrKDFParams) {
+		t.Fatalf("EncryptData with excessive scrypt memory error = %v, want %v", err, ErrKDFParams)
+	}
+}
@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// KeystoreVersion — версия формата Web3 Secret Storage
+const KeystoreVersion = 3
+
+// Keystore — файл ключа в формате Web3 Secret Storage v3. Address хранится
+// без префикса AddressPrefix, как 40 hex-символов, чтобы его понимали
+// стандартные кошельки.
+type Keystore struct {
+	Address string     `json:"address"`
+	Crypto  CryptoJSON `json:"crypto"`
+	ID      string     `json:"id"`
+	Version int        `json:"version"`
+}
+
+// EncryptKey шифрует приватный ключ паролем в формате v3
+func EncryptKey(privateKey *ecdsa.PrivateKey, password string, options KDFOptions) (*Keystore, error) {
+	keyBytes := make([]byte, 32)
+	privateKey.D.FillBytes(keyBytes)
+
+	cryptoJSON, err := EncryptData(keyBytes, password, options)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Keystore{
+		Address: strings.TrimPrefix(PubkeyToAddress(privateKey.PublicKey), AddressPrefix),
+		Crypto:  *cryptoJSON,
+		ID:      id,
+		Version: KeystoreVersion,
+	}, nil
+}
+
+// DecryptKey расшифровывает приватный ключ P-256 из файла v3 и проверяет,
+// что он соответствует адресу файла
+func DecryptKey(keystore *Keystore, password string) (*ecdsa.PrivateKey, error) {
+	if keystore.Version != KeystoreVersion {
+		return nil, fmt.Errorf("unsupported keystore version %d", keystore.Version)
+	}
+	if keystore.Crypto.Cipher != CipherAES128CTR {
+		return nil, fmt.Errorf("unsupported keystore cipher %q", keystore.Crypto.Cipher)
+	}
+
+	keyBytes, err := DecryptData(keystore.Crypto, password)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := PrivateKeyFromBytes(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	address := strings.TrimPrefix(PubkeyToAddress(privateKey.PublicKey), AddressPrefix)
+	if keystore.Address != "" && !strings.EqualFold(strings.TrimPrefix(keystore.Address, AddressPrefix), address) {
+		return nil, fmt.Errorf("keystore address %s does not match key address %s", keystore.Address, address)
+	}
+	return privateKey, nil
+}
+
+// PrivateKeyFromBytes восстанавливает приватный ключ P-256 из 32 байт скаляра
+func PrivateKeyFromBytes(keyBytes []byte) (*ecdsa.PrivateKey, error) {
+	if len(keyBytes) != 32 {
+		return nil, fmt.Errorf("invalid private key length %d", len(keyBytes))
+	}
+
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(keyBytes)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+
+	privateKey := new(ecdsa.PrivateKey)
+	privateKey.Curve = curve
+	privateKey.D = d
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = curve.ScalarBaseMult(keyBytes)
+	return privateKey, nil
+}
+
+// newUUID возвращает случайный UUID версии 4 для поля id
+func newUUID() (string, error) {
+	u := make([]byte, 16)
+	if _, err := rand.Read(u); err != nil {
+		return "", fmt.Errorf("failed to generate id: %w", err)
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // Версия 4
+	u[8] = (u[8] & 0x3f) | 0x80 // Вариант RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
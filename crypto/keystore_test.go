@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Тестовые векторы Web3 Secret Storage v3: ключ 7a28b5ba…, пароль "testpassword".
+// Адрес в векторах вычислен на secp256k1, поэтому он не задается.
+const testKeystoreKey = "7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d"
+
+var testKeystores = map[string]CryptoJSON{
+	"pbkdf2": {
+		Cipher:       CipherAES128CTR,
+		CipherText:   "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+		CipherParams: CipherParams{IV: "6087dab2f9fdbbfaddc31a909735c1e6"},
+		KDF:          "pbkdf2",
+		KDFParams: KDFParams{
+			DKLen: 32,
+			C:     262144,
+			PRF:   "hmac-sha256",
+			Salt:  "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd",
+		},
+		MAC: "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2",
+	},
+	"scrypt": {
+		Cipher:       CipherAES128CTR,
+		CipherText:   "d172bf743a674da9cdad04534d56926ef8358534d458fffccd4e6ad2fbde479c",
+		CipherParams: CipherParams{IV: "83dbcc02d8ccb40e466191a123791e0e"},
+		KDF:          "scrypt",
+		KDFParams: KDFParams{
+			DKLen: 32,
+			N:     262144,
+			R:     1,
+			P:     8,
+			Salt:  "ab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19",
+		},
+		MAC: "2103ac29920d71da29f15d75b4a16dbe95cfd7ff8faea1056c33131d846e3097",
+	},
+}
+
+func TestDecryptKeyKnownAnswer(t *testing.T) {
+	for name, cryptoJSON := range testKeystores {
+		t.Run(name, func(t *testing.T) {
+			keystore := &Keystore{Crypto: cryptoJSON, Version: KeystoreVersion}
+
+			privateKey, err := DecryptKey(keystore, "testpassword")
+			if err != nil {
+				t.Fatalf("DecryptKey: %v", err)
+			}
+			keyBytes := make([]byte, 32)
+			privateKey.D.FillBytes(keyBytes)
+			if got := hex.EncodeToString(keyBytes); got != testKeystoreKey {
+				t.Fatalf("key = %s, want %s", got, testKeystoreKey)
+			}
+
+			if _, err := DecryptKey(keystore, "wrongpassword"); err == nil {
+				t.Fatal("DecryptKey accepted a wrong password")
+			}
+		})
+	}
+}
+
+func TestEncryptKeyRoundTrip(t *testing.T) {
+	keyBytes, _ := hex.DecodeString(testKeystoreKey)
+	privateKey, err := PrivateKeyFromBytes(keyBytes)
+	if err != nil {
+		t.Fatalf("PrivateKeyFromBytes: %v", err)
+	}
+
+	keystore, err := EncryptKey(privateKey, "testpassword", KDFLight())
+	if err != nil {
+		t.Fatalf("EncryptKey: %v", err)
+	}
+	decrypted, err := DecryptKey(keystore, "testpassword")
+	if err != nil {
+		t.Fatalf("DecryptKey: %v", err)
+	}
+	if decrypted.D.Cmp(privateKey.D) != 0 {
+		t.Fatal("decrypted key differs from the original")
+	}
+
+	// Файл чужого адреса не должен приниматься
+	keystore.Address = "0000000000000000000000000000000000000000"
+	if _, err := DecryptKey(keystore, "testpassword"); err == nil {
+		t.Fatal("DecryptKey accepted a keystore with a foreign address")
+	}
+}
+
+// testLegacyAccount — запись aes-128-cbc, созданная до перехода на v3, с паролем "pass1"
+var testLegacyAccount = struct {
+	crypto    CryptoJSON
+	publicKey string
+}{
+	crypto: CryptoJSON{
+		Cipher:       CipherLegacyCBC,
+		CipherCode:   "f454e4565ff509f79ba14c48c80cf3af385f4342bf725e008983fcc2d2d2aa93ddc7da1b454fb83255b7f51d47012e4283ea24efe413e664baf7b5bc000f9ea7",
+		CipherParams: CipherParams{IV: "ab54e5aa6d92da3e911c4c2710d7e07b"},
+		KDF:          "scrypt",
+		KDFParams: KDFParams{
+			DKLen: 32,
+			N:     262144,
+			R:     8,
+			P:     1,
+			Salt:  "55ba6a41db898309b4c74f0957b1a73f9ba14b5d359dd9e282d75d53b955836b",
+		},
+		MAC: "1843c75e629deac9314cebb810a3fecf1bc25fdba749bc1e1d3d2b73a985af92",
+	},
+	publicKey: "0c78b45485d8329ac286ce712e14824e1c6360cbd3bc7d528907cbb82311e61e327f9f5666c3390d864988b28d14d623d7f5acfa5df1ab1cbbf325d599aaa8c2",
+}
+
+func TestDecryptLegacyCBC(t *testing.T) {
+	// В записях старого формата зашифрован hex приватного ключа
+	plaintext, err := DecryptData(testLegacyAccount.crypto, "pass1")
+	if err != nil {
+		t.Fatalf("DecryptData: %v", err)
+	}
+	keyBytes, err := hex.DecodeString(string(plaintext))
+	if err != nil {
+		t.Fatalf("decrypted key is not hex: %v", err)
+	}
+	privateKey, err := PrivateKeyFromBytes(keyBytes)
+	if err != nil {
+		t.Fatalf("PrivateKeyFromBytes: %v", err)
+	}
+	if got := hex.EncodeToString(MarshalPublicKey(&privateKey.PublicKey)); got != testLegacyAccount.publicKey {
+		t.Fatalf("public key = %s, want %s", got, testLegacyAccount.publicKey)
+	}
+
+	if _, err := DecryptData(testLegacyAccount.crypto, "pass2"); err == nil {
+		t.Fatal("DecryptData accepted a wrong password")
+	}
+}
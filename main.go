@@ -3,40 +3,160 @@
 package main
 
 import (
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/HHpCpp/AVAF/accounts"
 	"github.com/HHpCpp/AVAF/adb"
-	"github.com/HHpCpp/AVAF/pos"
+	"github.com/HHpCpp/AVAF/amount"
+	"github.com/HHpCpp/AVAF/blockchain"
+	"github.com/HHpCpp/AVAF/crypto"
 )
 
 func main() {
-	db, err := adb.NewLevelDB("db/LevelDB")
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		if err := runVerify(os.Args[2:]); err != nil {
+			log.Fatalf("Chain verification failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-addresses" {
+		if err := runMigrateAddresses(os.Args[2:]); err != nil {
+			log.Fatalf("Address migration failed: %v", err)
+		}
+		return
+	}
+
+	// Пример: ключ аккаунта, которому генезис выделяет начальный баланс
+	prv, address, err := accounts.GenerateKeyPair()
+	if err != nil {
+		log.Fatalf("Failed to generate key pair: %v", err)
+	}
+
+	genesis := blockchain.DefaultGenesis()
+	genesis.Alloc = []blockchain.GenesisAlloc{{
+		Address:   address,
+		PublicKey: hex.EncodeToString(crypto.MarshalPublicKey(&prv.PublicKey)),
+		Balance:   amount.MustParse("2000"),
+	}}
+	genesis.Validators = []blockchain.GenesisStake{{
+		Address: address,
+		Stake:   amount.MustParse("100"),
+	}}
+
+	// Демонстрационная цепочка живет в памяти: у каждого запуска свой генезис
+	bc, err := blockchain.NewBlockchainWithGenesis(adb.NewMemoryDB(), genesis)
+	if err != nil {
+		log.Fatalf("Failed to create blockchain: %v", err)
+	}
+
+	// Единственный валидатор генезиса производит блоки этого узла
+	if err := bc.StakingWallet.RegisterValidatorKey(address, prv); err != nil {
+		log.Fatalf("Failed to register validator key: %v", err)
+	}
+
+	// Стейкинг — транзакция: стейк меняется, когда она попадает в блок
+	if _, err := bc.StakeTokens(address, amount.MustParse("1000"), prv); err != nil {
+		log.Fatalf("Failed to stake tokens: %v", err)
+	}
+	if _, err := bc.CommitPending(); err != nil {
+		log.Fatalf("Failed to produce block: %v", err)
+	}
+
+	bal, _ := bc.AccountManager.GetBalance(address)
+	stake, _ := bc.StakingWallet.GetStake(address)
+	fmt.Println(bal, stake)
+}
+
+// runVerify проверяет целостность сохраненной цепочки:
+// avaf verify [-db path] [-from N] [-to N]
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	dbPath := flags.String("db", "db/LevelDB", "path to the LevelDB directory")
+	genesisPath := flags.String("genesis", "", "path to the genesis JSON file (default: devnet genesis)")
+	from := flags.Int("from", 0, "first block index to verify")
+	to := flags.Int("to", -1, "last block index to verify (-1 for the chain tip)")
+	flags.Parse(args)
+
+	// Проверка не должна менять базу: несуществующий путь — ошибка,
+	// а не новая цепочка с одним генезис-блоком
+	db, err := adb.OpenLevelDBReadOnly(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	genesis := blockchain.DefaultGenesis()
+	if *genesisPath != "" {
+		if genesis, err = blockchain.LoadGenesis(*genesisPath); err != nil {
+			return err
+		}
+	}
+
+	if err := blockchain.VerifyStore(db, genesis, *from, *to); err != nil {
+		return err
+	}
+
+	lastIndex, err := blockchain.GetLastBlockIndex(db)
+	if err != nil {
+		return err
+	}
+	toIndex := *to
+	if toIndex < 0 || toIndex > lastIndex {
+		toIndex = lastIndex
+	}
+	fmt.Printf("Blocks %d..%d verified\n", *from, toIndex)
+	return nil
+}
+
+// runMigrateAddresses переносит аккаунты со старой схемой адресов на каноничные:
+// avaf migrate-addresses [-db path] [-genesis path]
+func runMigrateAddresses(args []string) error {
+	flags := flag.NewFlagSet("migrate-addresses", flag.ExitOnError)
+	dbPath := flags.String("db", "db/LevelDB", "path to the LevelDB directory")
+	genesisPath := flags.String("genesis", "", "path to the genesis JSON file (default: devnet genesis)")
+	flags.Parse(args)
+
+	// Миграция переносит существующие аккаунты: опечатка в пути не должна
+	// создавать новую базу с генезис-блоком
+	db, err := adb.OpenLevelDB(*dbPath)
 	if err != nil {
-		log.Fatalf("Failed to open LevelDB: %v", err)
+		return err
 	}
 	defer db.Close()
 
-	// Создаем AccountManager
-	accountManager := accounts.NewAccountManager(db)
+	lastIndex, err := blockchain.GetLastBlockIndex(db)
+	if err != nil {
+		return err
+	}
+	if lastIndex < 0 {
+		return fmt.Errorf("no chain stored in %s", *dbPath)
+	}
 
-	// Создаем StakingWallet
-	stakingWallet := pos.NewStakingWallet(db)
+	genesis := blockchain.DefaultGenesis()
+	if *genesisPath != "" {
+		if genesis, err = blockchain.LoadGenesis(*genesisPath); err != nil {
+			return err
+		}
+	}
 
-	// Пример создания аккаунтов
-	address, _, err := accountManager.CreateAccount("password2", 2000.0)
+	bc, err := blockchain.NewBlockchainWithGenesis(db, genesis)
 	if err != nil {
-		log.Fatalf("Failed to generate key pair: %v", err)
+		return err
 	}
 
-	prv, _ := accountManager.GetPrivateKey(address, "password2")
-	err = stakingWallet.StakeTokens(address, 1000.0, prv)
+	migrations, err := bc.MigrateAddresses()
 	if err != nil {
-		log.Fatalf("Failed to load account 1: %v", err)
+		return err
+	}
+	for _, migration := range migrations {
+		fmt.Printf("%s -> %s\n", migration.OldAddress, migration.NewAddress)
 	}
-	bal, _ := accountManager.GetBalance(address)
-	fmt.Println(bal)
+	fmt.Printf("Migrated %d accounts\n", len(migrations))
+	return nil
 }
 
 /* db, err := avafdb.NewLevelDB("db/leveldb")
@@ -61,7 +181,7 @@ bc.CreateTransaction(Address, Address1, prv, 10, "test")
 // bcdl2, _ := bc.AccountManager.GetBalance(Address1)
 // fmt.Println(tx, bcdl1, bcdl2)
 
-// GetBlockByHash("c364c3b9e3ac3371e4d72d4c7341121e8e04d3121e29abc008558a3cbc7a2e0d") NOT WORKING
+// GetBlockByHash("c364c3b9e3ac3371e4d72d4c7341121e8e04d3121e29abc008558a3cbc7a2e0d")
 gta, _ := bc.AccountManager.GetAllAccounts()
 cua, _ := bc.GetAllBlocks()
 // AVAFu62d2b688ef6679219e007cee17933cc587857c68 AVAFue89cf58aff73c4d22bc23ce331ad452801ab03e4
@@ -3,37 +3,77 @@ package pos
 import (
 	"crypto/ecdsa"
 	"crypto/sha256"
-	"encoding/hex"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
-	"math/rand"
-	"strconv"
+	"sort"
 	"strings"
-	"time"
-
-	crg "crypto/rand"
+	"sync"
 
 	"github.com/HHpCpp/AVAF/accounts"
 	"github.com/HHpCpp/AVAF/adb"
-	ye "github.com/HHpCpp/AVAF/crypto"
+	"github.com/HHpCpp/AVAF/amount"
 )
 
+// StakingAddress — служебный адрес получателя транзакций стейкинга.
+// Аккаунта с этим адресом нет: сумма такой транзакции переходит в стейк отправителя.
+const StakingAddress = "AVAFuNETWORKaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+// ErrNoValidatorSet возвращается, если для блока не сохранен набор валидаторов
+var ErrNoValidatorSet = errors.New("validator set is not recorded for block")
+
 type StakingWallet struct {
 	Address     string                       // Адрес кошелька для стейкинга
-	db          *adb.LevelDB                 // LevelDB для хранения данных
+	db          adb.KVStore                  // Хранилище данных
+	accounts    *accounts.AccountManager     // Общий с цепочкой менеджер аккаунтов
+	keysMu      sync.RWMutex                 // Защищает privateKeys
 	privateKeys map[string]*ecdsa.PrivateKey // Хранение приватных ключей для подписи
 }
 
-func NewStakingWallet(db *adb.LevelDB) *StakingWallet {
+// NewStakingWallet создает кошелек стейкинга. am должен быть тем же
+// менеджером аккаунтов, что и у цепочки. Стейки меняются только генезисом
+// и транзакциями стейкинга в блоках.
+func NewStakingWallet(db adb.KVStore, am *accounts.AccountManager) *StakingWallet {
 	return &StakingWallet{
-		Address:     "AVAFuNETWORKaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		Address:     StakingAddress,
 		db:          db,
+		accounts:    am,
 		privateKeys: make(map[string]*ecdsa.PrivateKey),
 	}
 }
 
+// RegisterValidatorKey сохраняет в памяти ключ валидатора, которым узел будет
+// подписывать блоки, когда этот валидатор выбран производителем слота
+func (sw *StakingWallet) RegisterValidatorKey(address string, privateKey *ecdsa.PrivateKey) error {
+	if privateKey == nil {
+		return fmt.Errorf("private key is required")
+	}
+
+	publicKey, err := sw.accounts.GetPublicKey(address)
+	if err != nil {
+		return fmt.Errorf("failed to get validator public key: %w", err)
+	}
+	if publicKey.X.Cmp(privateKey.PublicKey.X) != 0 || publicKey.Y.Cmp(privateKey.PublicKey.Y) != 0 {
+		return fmt.Errorf("private key does not match the account address")
+	}
+
+	sw.keysMu.Lock()
+	sw.privateKeys[address] = privateKey
+	sw.keysMu.Unlock()
+	return nil
+}
+
+// ValidatorKey возвращает зарегистрированный на этом узле ключ валидатора
+func (sw *StakingWallet) ValidatorKey(address string) (*ecdsa.PrivateKey, bool) {
+	sw.keysMu.RLock()
+	defer sw.keysMu.RUnlock()
+
+	privateKey, ok := sw.privateKeys[address]
+	return privateKey, ok
+}
+
 func (sw *StakingWallet) GetAccount(address string) (*accounts.Account, error) {
 	key := "account_" + address
 	fmt.Printf("Loading account with key: %s\n", key) // Отладочный вывод
@@ -52,102 +92,75 @@ func (sw *StakingWallet) GetAccount(address string) (*accounts.Account, error) {
 	return &account, nil
 }
 
-func (sw *StakingWallet) StakeTokens(accountAddress string, amount float64, privateKey *ecdsa.PrivateKey) error {
-	// Проверяем, что приватный ключ не равен nil
-	if privateKey == nil {
-		return fmt.Errorf("private key is required for staking")
+// GetStake возвращает текущий стейк адреса (0, если адрес не стейкал)
+func (sw *StakingWallet) GetStake(address string) (amount.Amount, error) {
+	data, err := sw.db.Load("stake_" + address)
+	if errors.Is(err, adb.ErrNotFound) {
+		return amount.Zero, nil
 	}
-
-	// Проверяем, что приватный ключ действителен
-	if !privateKey.Curve.IsOnCurve(privateKey.PublicKey.X, privateKey.PublicKey.Y) {
-		return fmt.Errorf("invalid private key: public key is not on the curve")
-	}
-
-	// Получаем аккаунт
-	fmt.Printf("Attempting to load account: %s\n", accountAddress) // Отладочный вывод
-	account, err := sw.GetAccount(accountAddress)
 	if err != nil {
-		return fmt.Errorf("failed to get account: %w", err)
-	}
-	fmt.Printf("Account loaded successfully: %+v\n", account) // Отладочный вывод
-
-	// Извлекаем баланс для валюты "AVAF"
-	balance, ok := account.Balance["AVAF"]
-	if !ok {
-		return fmt.Errorf("currency 'AVAF' not found in account balance")
-	}
-	fmt.Printf("Current balance: %f\n", balance) // Отладочный вывод
-
-	// Проверяем, что у аккаунта достаточно токенов
-	if amount > balance {
-		return fmt.Errorf("insufficient balance")
-	}
-
-	// Проверяем, что приватный ключ соответствует адресу аккаунта
-	expectedAddress := ye.PubkeyToAddress(privateKey.PublicKey)
-	if expectedAddress != accountAddress {
-		return fmt.Errorf("private key does not match the account address")
-	}
-
-	// Подписываем транзакцию стейкинга
-	stakeTx := &StakeTransaction{
-		AccountAddress: accountAddress,
-		Amount:         amount,
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
+		return amount.Zero, err
 	}
+	return parseStake(address, data)
+}
 
-	if err := stakeTx.Sign(privateKey); err != nil {
-		return fmt.Errorf("failed to sign stake transaction: %w", err)
+// parseStake разбирает сохраненный стейк; старые записи в формате "%f" тоже читаются
+func parseStake(address string, data []byte) (amount.Amount, error) {
+	stake, err := amount.Parse(string(data))
+	if err != nil {
+		return amount.Zero, fmt.Errorf("failed to parse stake for address %s: %w", address, err)
 	}
+	return stake, nil
+}
 
-	// Проверяем подпись
-	publicKey := &privateKey.PublicKey
-	valid, err := stakeTx.Verify(publicKey)
+// MigrateStake добавляет в пакет перенос стейка со старого адреса аккаунта
+// на каноничный (см. AccountManager.MigrateAddresses)
+func (sw *StakingWallet) MigrateStake(batch *adb.Batch, oldAddress, newAddress string) error {
+	stake, err := sw.GetStake(oldAddress)
 	if err != nil {
-		return fmt.Errorf("failed to verify stake transaction: %w", err)
+		return err
 	}
-	if !valid {
-		return fmt.Errorf("invalid stake transaction signature")
+	if stake.IsZero() {
+		return nil
 	}
 
-	// Вычитаем сумму из баланса
-	account.Balance["AVAF"] = balance - amount
-	fmt.Printf("New balance after staking: %f\n", account.Balance["AVAF"]) // Отладочный вывод
-
-	// Сохраняем обновленный аккаунт
-	if err := sw.saveAccount(account); err != nil {
-		return fmt.Errorf("failed to save account: %w", err)
+	existing, err := sw.GetStake(newAddress)
+	if err != nil {
+		return err
 	}
-	fmt.Println("Account saved successfully") // Отладочный вывод
-
-	// Сохраняем стейк в LevelDB
-	if err := sw.SaveStake(accountAddress, amount); err != nil {
-		return fmt.Errorf("failed to save stake: %w", err)
+	total, err := existing.Add(stake)
+	if err != nil {
+		return fmt.Errorf("failed to merge stake of %s: %w", oldAddress, err)
 	}
-	fmt.Println("Stake saved successfully") // Отладочный вывод
 
+	sw.PutStake(batch, newAddress, total)
+	batch.Delete("stake_" + oldAddress)
 	return nil
 }
 
-func (sw *StakingWallet) saveAccount(account *accounts.Account) error {
-	data, err := json.Marshal(account)
-	if err != nil {
-		return fmt.Errorf("failed to marshal account: %w", err)
-	}
-
-	return sw.db.Save("account_"+account.Address, data)
+// PutStake добавляет запись стейка в пакет изменений
+func (sw *StakingWallet) PutStake(batch *adb.Batch, address string, stake amount.Amount) {
+	batch.Save("stake_"+address, []byte(stake.String()))
 }
 
-func (sw *StakingWallet) SaveStake(address string, stake float64) error {
-	stakeBytes := []byte(fmt.Sprintf("%f", stake))
-	return sw.db.Save("stake_"+address, stakeBytes)
+// AllValidators возвращает текущую таблицу стейков или ошибку, если она пуста
+func (sw *StakingWallet) AllValidators() (map[string]amount.Amount, error) {
+	validators, err := sw.Stakes()
+	if err != nil {
+		return nil, err
+	}
+	if len(validators) == 0 {
+		return nil, fmt.Errorf("no validators available")
+	}
+	return validators, nil
 }
 
-func (sw *StakingWallet) AllValidators() (map[string]float64, error) {
-	validators := make(map[string]float64)
+// Stakes возвращает текущую таблицу стейков stake_; адреса с нулевым стейком пропускаются
+func (sw *StakingWallet) Stakes() (map[string]amount.Amount, error) {
+	validators := make(map[string]amount.Amount)
 
-	// Создаем итератор для LevelDB
-	iter := sw.db.NewIterator()
+	// Итерируем только по ключам стейков
+	iter := sw.db.NewPrefixIterator("stake_")
 	defer iter.Release()
 
 	// Проходим по всем ключам
@@ -155,14 +168,13 @@ func (sw *StakingWallet) AllValidators() (map[string]float64, error) {
 		key := string(iter.Key())
 		value := iter.Value()
 
-		// Проверяем, что ключ начинается с "stake_"
-		if strings.HasPrefix(key, "stake_") {
-			address := strings.TrimPrefix(key, "stake_")
-			stake, err := strconv.ParseFloat(string(value), 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse stake for address %s: %w", address, err)
-			}
+		address := strings.TrimPrefix(key, "stake_")
+		stake, err := parseStake(address, value)
+		if err != nil {
+			return nil, err
+		}
 
+		if !stake.IsZero() {
 			validators[address] = stake
 		}
 	}
@@ -171,91 +183,101 @@ func (sw *StakingWallet) AllValidators() (map[string]float64, error) {
 		return nil, fmt.Errorf("iterator error: %w", err)
 	}
 
-	if len(validators) == 0 {
-		return nil, fmt.Errorf("no validators available")
-	}
-
 	return validators, nil
 }
 
-func (sw *StakingWallet) SelectValidator() (string, error) {
-	// Получаем всех валидаторов
-	validators, err := sw.AllValidators()
-	if err != nil {
-		return "", fmt.Errorf("failed to get validators: %w", err)
-	}
+// validatorSetKey возвращает ключ набора валидаторов после блока blockIndex
+func validatorSetKey(blockIndex int) string {
+	return fmt.Sprintf("validators_%010d", blockIndex)
+}
 
-	// Считаем общий стейк
-	totalStake := 0.0
-	for _, stake := range validators {
-		totalStake += stake
+// ValidatorSet возвращает набор валидаторов, сложившийся после применения
+// блока blockIndex. Производитель следующего блока выбирается из этого
+// набора, поэтому проверка старого блока не зависит от текущих стейков.
+func (sw *StakingWallet) ValidatorSet(blockIndex int) (map[string]amount.Amount, error) {
+	data, err := sw.db.Load(validatorSetKey(blockIndex))
+	if errors.Is(err, adb.ErrNotFound) {
+		return nil, fmt.Errorf("%w %d", ErrNoValidatorSet, blockIndex)
 	}
-
-	if totalStake == 0 {
-		return "", fmt.Errorf("no validators available")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load validator set: %w", err)
 	}
 
-	// Генерируем случайное число
-	rand.Seed(time.Now().UnixNano())
-	r := rand.Float64() * totalStake
+	validators := make(map[string]amount.Amount)
+	if err := json.Unmarshal(data, &validators); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal validator set of block %d: %w", blockIndex, err)
+	}
+	return validators, nil
+}
 
-	// Выбираем валидатора
+// PutValidatorSet добавляет в пакет набор валидаторов после блока blockIndex
+func (sw *StakingWallet) PutValidatorSet(batch *adb.Batch, blockIndex int, validators map[string]amount.Amount) error {
+	set := make(map[string]amount.Amount, len(validators))
 	for address, stake := range validators {
-		r -= stake
-		if r <= 0 {
-			return address, nil
+		if !stake.IsZero() {
+			set[address] = stake
 		}
 	}
 
-	return "", fmt.Errorf("failed to select validator")
-}
-
-// StakeTransaction представляет транзакцию стейкинга
-type StakeTransaction struct {
-	AccountAddress string  `json:"accountAddress"`
-	Amount         float64 `json:"amount"`
-	Timestamp      string  `json:"timestamp"`
-	Signature      string  `json:"signature"`
-}
-
-// Sign подписывает транзакцию стейкинга
-func (st *StakeTransaction) Sign(privateKey *ecdsa.PrivateKey) error {
-	hash := st.Hash()
-	r, s, err := ecdsa.Sign(crg.Reader, privateKey, hash[:])
+	// Ключи map кодируются в отсортированном порядке
+	data, err := json.Marshal(set)
 	if err != nil {
-		return fmt.Errorf("failed to sign stake transaction: %w", err)
+		return fmt.Errorf("failed to marshal validator set: %w", err)
 	}
-
-	signature := append(r.Bytes(), s.Bytes()...)
-	st.Signature = hex.EncodeToString(signature)
+	batch.Save(validatorSetKey(blockIndex), data)
 	return nil
 }
 
-// Verify проверяет подпись транзакции
-func (st *StakeTransaction) Verify(publicKey *ecdsa.PublicKey) (bool, error) {
-	signature, err := hex.DecodeString(st.Signature)
-	if err != nil {
-		return false, fmt.Errorf("failed to decode signature: %w", err)
+// SelectProposer детерминированно выбирает производителя блока для слота.
+// Выбор взвешен по стейку и зависит только от данных цепочки: набора
+// валидаторов родительского блока, его хеша и номера слота, поэтому все
+// узлы получают один и тот же результат.
+func SelectProposer(validators map[string]amount.Amount, prevHash string, slot uint64) (string, error) {
+	var err error
+
+	// Обходим валидаторов в стабильном порядке, а не в порядке обхода map
+	addresses := make([]string, 0, len(validators))
+	for address := range validators {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	// Считаем общий стейк
+	totalStake := amount.Zero
+	for _, address := range addresses {
+		if totalStake, err = totalStake.Add(validators[address]); err != nil {
+			return "", fmt.Errorf("failed to sum stakes: %w", err)
+		}
 	}
 
-	if len(signature) != 64 {
-		return false, errors.New("invalid signature length")
+	if totalStake.IsZero() {
+		return "", fmt.Errorf("no validators available")
 	}
 
-	r := new(big.Int).SetBytes(signature[:32])
-	s := new(big.Int).SetBytes(signature[32:])
-	hash := st.Hash()
+	// Точка выбора на отрезке [0, totalStake)
+	seed := ProposerSeed(prevHash, slot)
+	r := new(big.Int).Mod(
+		new(big.Int).SetBytes(seed[:]),
+		new(big.Int).SetUint64(totalStake.Nano()),
+	).Uint64()
+
+	// Выбираем валидатора
+	for _, address := range addresses {
+		stake := validators[address].Nano()
+		if r < stake {
+			return address, nil
+		}
+		r -= stake
+	}
 
-	return ecdsa.Verify(publicKey, hash[:], r, s), nil
+	return "", fmt.Errorf("failed to select validator")
 }
 
-// Hash возвращает хеш транзакции
-func (st *StakeTransaction) Hash() [32]byte {
-	data := fmt.Sprintf(
-		"%s-%.18f-%s",
-		st.AccountAddress,
-		st.Amount,
-		st.Timestamp,
-	)
-	return sha256.Sum256([]byte(data))
+// ProposerSeed возвращает случайное значение слота: SHA-256 от хеша
+// предыдущего блока и номера слота в big-endian
+func ProposerSeed(prevHash string, slot uint64) [32]byte {
+	data := make([]byte, 0, len(prevHash)+8)
+	data = append(data, prevHash...)
+	data = binary.BigEndian.AppendUint64(data, slot)
+	return sha256.Sum256(data)
 }
@@ -6,11 +6,11 @@ import (
 	"github.com/HHpCpp/AVAF/adb"
 )
 
-func PrintAllDBEntries(db *adb.LevelDB) {
+func PrintAllDBEntries(db adb.KVStore) {
 	iter := db.NewIterator()
 	defer iter.Release()
 
-	fmt.Println("All entries in LevelDB:")
+	fmt.Println("All entries in database:")
 	for iter.Next() {
 		key := string(iter.Key())
 		value := string(iter.Value())